@@ -0,0 +1,246 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	caRotateCommand = `docker swarm ca --rotate --detach --quiet`
+	caCert          = `--ca-cert %s`
+	caKey           = `--ca-key %s`
+
+	// caRotateTimeout is how long RotateCA waits for the rotation to
+	// complete by default
+	caRotateTimeout = time.Minute * 10
+)
+
+// CAInfo describes the certificate authority of a swarm and the expiry of
+// its certificates.
+type CAInfo struct {
+	// NodeCertExpiry is the validity period of node certificates which are
+	// renewed automatically before they expire
+	NodeCertExpiry time.Duration
+
+	// RootCAExpiry is when the swarm's root CA certificate expires. The
+	// root CA is not renewed automatically and must be rotated before then.
+	RootCAExpiry time.Time
+
+	// RootRotationInProgress is true while the root CA is being rotated
+	RootRotationInProgress bool
+}
+
+// ExpiresWithin returns true if the root CA certificate expires within d of
+// now (e.g: to alert before it must be rotated).
+func (ca CAInfo) ExpiresWithin(d time.Duration) bool {
+	return time.Until(ca.RootCAExpiry) < d
+}
+
+// parseCAInfo returns the CAInfo of the swarm cluster
+func parseCAInfo(cluster ClusterInfo) (CAInfo, error) {
+	info := CAInfo{
+		NodeCertExpiry:         cluster.Spec.CAConfig.NodeCertExpiry,
+		RootRotationInProgress: cluster.RootRotationInProgress,
+	}
+
+	block, _ := pem.Decode([]byte(cluster.TLSInfo.TrustRoot))
+	if block == nil {
+		return CAInfo{}, fmt.Errorf("error no root CA certificate found")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return CAInfo{}, fmt.Errorf("error parsing root CA certificate: %w", err)
+	}
+	info.RootCAExpiry = cert.NotAfter
+
+	return info, nil
+}
+
+// GetCAInfo returns the certificate expiry and root CA rotation status of
+// the swarm's certificate authority from a manager.
+func (m *Manager) GetCAInfo() (CAInfo, error) {
+	if err := m.ensureManager(); err != nil {
+		return CAInfo{}, fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	node, err := m.GetInfo()
+	if err != nil {
+		return CAInfo{}, fmt.Errorf("error getting node info: %w", err)
+	}
+	if node.Swarm.Cluster == nil {
+		return CAInfo{}, fmt.Errorf("error no swarm cluster found")
+	}
+
+	return parseCAInfo(*node.Swarm.Cluster)
+}
+
+// CARotateOptions are the options of RotateCA
+type CARotateOptions struct {
+	// CACertPath and CAKeyPath are the paths on the leader of a PEM encoded
+	// root CA certificate and key to rotate to. A new root CA is generated
+	// if they are empty.
+	CACertPath string
+	CAKeyPath  string
+
+	// Timeout is how long to wait for the rotation to complete (default
+	// 10 minutes)
+	Timeout time.Duration
+}
+
+// buildCARotateCommand builds the `docker swarm ca --rotate` command
+func buildCARotateCommand(opts CARotateOptions) string {
+	args := []string{caRotateCommand}
+	if opts.CACertPath != "" {
+		args = append(args, fmt.Sprintf(caCert, ShellQuote(opts.CACertPath)))
+	}
+	if opts.CAKeyPath != "" {
+		args = append(args, fmt.Sprintf(caKey, ShellQuote(opts.CAKeyPath)))
+	}
+	return strings.Join(args, " ")
+}
+
+// RotateCA rotates the swarm's root CA from the leader and waits until
+// every reachable node has a certificate issued by the new root CA. Nodes
+// that are Down cannot be rotated and the swarm reports the rotation as in
+// progress until they rejoin, in which case a *CARotationPendingError is
+// returned. The CAInfo of the new root CA is returned on success.
+func (m *Manager) RotateCA(opts CARotateOptions) (CAInfo, error) {
+	if (opts.CACertPath == "") != (opts.CAKeyPath == "") {
+		return CAInfo{}, fmt.Errorf("error both a CA certificate and key must be given")
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = caRotateTimeout
+	}
+
+	var info CAInfo
+	err := m.onLeader(func() error {
+		if _, err := m.runMutatingCmd(buildCARotateCommand(opts)); err != nil {
+			return fmt.Errorf("error rotating CA: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		if err := m.waitForRotation(ctx); err != nil {
+			return err
+		}
+
+		var err error
+		info, err = m.GetCAInfo()
+		if err != nil {
+			return fmt.Errorf("error getting new CA info: %w", err)
+		}
+		log.Infof("Successfully rotated CA, the new root CA expires at %s", info.RootCAExpiry)
+
+		return nil
+	})
+	if err != nil {
+		return CAInfo{}, err
+	}
+
+	return info, nil
+}
+
+// waitForRotation waits until the root CA rotation is complete or returns a
+// *CARotationPendingError if it is only waiting on nodes that are Down.
+func (m *Manager) waitForRotation(ctx context.Context) error {
+	interval := drainPollMin
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			done, down, err := m.rotationDone()
+			if err != nil {
+				log.WithError(err).Warn("error checking CA rotation (retrying)")
+			} else if done {
+				return nil
+			} else if len(down) > 0 {
+				return &CARotationPendingError{Nodes: down}
+			}
+
+			interval = nextInterval(interval)
+			timer.Reset(interval)
+		case <-ctx.Done():
+			return fmt.Errorf("error timed out waiting for CA rotation to complete")
+		}
+	}
+}
+
+// rotationDone returns true if the root CA rotation is complete. If every
+// node that is Ready has a certificate issued by the new root CA the nodes
+// that are Down and still pending are returned.
+func (m *Manager) rotationDone() (bool, []string, error) {
+	m.info.invalidate()
+
+	node, err := m.GetInfo()
+	if err != nil {
+		return false, nil, fmt.Errorf("error getting node info: %w", err)
+	}
+	if node.Swarm.Cluster == nil {
+		return false, nil, fmt.Errorf("error no swarm cluster found")
+	}
+	if !node.Swarm.Cluster.RootRotationInProgress {
+		return true, nil, nil
+	}
+
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return false, nil, fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	var pending, down []string
+	for _, node := range nodes {
+		if node.TLSStatus == "" || node.TLSStatus == "Ready" {
+			continue
+		}
+		if node.Status == "Ready" {
+			pending = append(pending, node.Hostname)
+		} else {
+			down = append(down, node.Hostname)
+		}
+	}
+
+	if len(pending) > 0 {
+		log.Infof("Waiting for CA rotation of %s ...", strings.Join(pending, ","))
+		return false, nil, nil
+	}
+
+	if len(down) > 0 {
+		return false, down, nil
+	}
+
+	log.Info("Waiting for CA rotation to complete ...")
+	return false, nil, nil
+}
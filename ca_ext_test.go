@@ -0,0 +1,87 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestRotateCA tests that RotateCA waits for the rotation to complete and
+// returns the CAInfo of the new root CA.
+func TestRotateCA(t *testing.T) {
+	assert := assert.New(t)
+
+	trustRoot, err := ioutil.ReadFile("testdata/ca.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	quoted, _ := json.Marshal(string(trustRoot))
+
+	info := func(inProgress bool) swarmtest.Response {
+		return swarmtest.Response{Stdout: fmt.Sprintf(
+			`{"Name": "dm1", "Swarm": {"NodeID": "1", "LocalNodeState": "active", "ControlAvailable": true, "Cluster": {"ID": "c1", "TLSInfo": {"TrustRoot": %s}, "RootRotationInProgress": %t}}}`,
+			quoted, inProgress,
+		)}
+	}
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker info`, info(true), info(true), info(false))
+	runner.On(`^docker swarm ca --rotate`)
+
+	ca, err := m.RotateCA(swarm.CARotateOptions{})
+	assert.NoError(err)
+	assert.False(ca.RootRotationInProgress)
+	assert.True(ca.RootCAExpiry.Equal(time.Date(2126, time.September, 22, 13, 13, 19, 0, time.UTC)))
+	assert.Equal([]string{"docker swarm ca --rotate --detach --quiet"}, runner.Commands(`^docker swarm ca`))
+}
+
+// TestRotateCADownNodes tests that RotateCA returns a
+// *swarm.CARotationPendingError rather than the CAInfo of the old root CA when
+// the rotation is only waiting on nodes that are Down.
+func TestRotateCADownNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker info`, swarmtest.Response{
+		Stdout: `{"Name": "dm1", "Swarm": {"NodeID": "1", "LocalNodeState": "active", "ControlAvailable": true, "Cluster": {"ID": "c1", "RootRotationInProgress": true}}}`,
+	})
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: `{"ID": "1", "Hostname": "dm1", "Status": "Ready", "ManagerStatus": "Leader", "TLSStatus": "Ready"}
+{"ID": "2", "Hostname": "dw1", "Status": "Down", "ManagerStatus": "", "TLSStatus": "Needs Rotation"}
+`})
+	runner.On(`^docker swarm ca --rotate`)
+
+	ca, err := m.RotateCA(swarm.CARotateOptions{})
+
+	var pendingErr *swarm.CARotationPendingError
+	if assert.ErrorAs(err, &pendingErr) {
+		assert.Equal([]string{"dw1"}, pendingErr.Nodes)
+	}
+	assert.Equal(swarm.CAInfo{}, ca)
+}
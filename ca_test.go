@@ -0,0 +1,106 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testRootCA returns a PEM encoded self-signed CA certificate that expires
+// at notAfter.
+func testRootCA(t *testing.T, notAfter time.Time) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "swarm-ca"},
+		NotBefore:             notAfter.Add(-time.Hour * 24 * 365),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// TestParseCAInfo tests reading the expiry of the root CA and node
+// certificates from the cluster information.
+func TestParseCAInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	notAfter := time.Now().Add(time.Hour * 24 * 30).Truncate(time.Second).UTC()
+
+	cluster := ClusterInfo{
+		Spec:                   ClusterSpec{CAConfig: CAConfig{NodeCertExpiry: time.Hour * 24 * 90}},
+		TLSInfo:                TLSInfo{TrustRoot: testRootCA(t, notAfter)},
+		RootRotationInProgress: true,
+	}
+
+	info, err := parseCAInfo(cluster)
+	assert.Nil(err)
+	assert.Equal(time.Hour*24*90, info.NodeCertExpiry)
+	assert.True(info.RootCAExpiry.Equal(notAfter))
+	assert.True(info.RootRotationInProgress)
+	assert.True(info.ExpiresWithin(time.Hour * 24 * 60))
+	assert.False(info.ExpiresWithin(time.Hour * 24 * 7))
+
+	_, err = parseCAInfo(ClusterInfo{})
+	assert.Error(err)
+}
+
+// TestRotateCAOptions tests building the CA rotation command and that a
+// CA certificate and key must be given together.
+func TestRotateCAOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(
+		"docker swarm ca --rotate --detach --quiet",
+		buildCARotateCommand(CARotateOptions{}),
+	)
+	assert.Equal(
+		"docker swarm ca --rotate --detach --quiet --ca-cert '/etc/ca.pem' --ca-key '/etc/ca-key.pem'",
+		buildCARotateCommand(CARotateOptions{CACertPath: "/etc/ca.pem", CAKeyPath: "/etc/ca-key.pem"}),
+	)
+
+	switcher, _ := NewNullSwitcher()
+	m, err := NewManager(switcher)
+	assert.Nil(err)
+	_, err = m.RotateCA(CARotateOptions{CACertPath: "/etc/ca.pem"})
+	assert.Error(err)
+}
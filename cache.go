@@ -0,0 +1,65 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"sync"
+	"time"
+)
+
+// infoCache caches the NodeInfo of the node the Manager is currently
+// switched to for a short period of time to avoid repeated `docker info`
+// calls in quick succession within a single operation.
+type infoCache struct {
+	sync.Mutex
+
+	node    string
+	info    NodeInfo
+	expires time.Time
+}
+
+func (c *infoCache) get(node string) (NodeInfo, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.node == "" || c.node != node || time.Now().After(c.expires) {
+		return NodeInfo{}, false
+	}
+
+	return c.info, true
+}
+
+func (c *infoCache) set(node string, info NodeInfo, ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.node = node
+	c.info = info
+	c.expires = time.Now().Add(ttl)
+}
+
+func (c *infoCache) invalidate() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.node = ""
+	c.info = NodeInfo{}
+}
@@ -0,0 +1,55 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestInfoCache tests that cached NodeInfo is only returned for the same
+// node until it expires or is invalidated.
+func TestInfoCache(t *testing.T) {
+	assert := assert.New(t)
+
+	var c infoCache
+
+	_, ok := c.get("")
+	assert.False(ok)
+
+	c.set("dm1", NodeInfo{Name: "dm1"}, time.Minute)
+	info, ok := c.get("dm1")
+	assert.True(ok)
+	assert.Equal("dm1", info.Name)
+
+	_, ok = c.get("dw1")
+	assert.False(ok)
+
+	c.invalidate()
+	_, ok = c.get("dm1")
+	assert.False(ok)
+
+	c.set("dm1", NodeInfo{Name: "dm1"}, -time.Second)
+	_, ok = c.get("dm1")
+	assert.False(ok)
+}
@@ -0,0 +1,174 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	taskInspectCommand = `docker inspect --type task --format "{{ json . }}" %s`
+)
+
+// TaskSpec is the specification of a task as returned by `docker inspect`
+type TaskSpec struct {
+	Resources TaskResources
+}
+
+// TaskResources are the resource limits and reservations of a task
+type TaskResources struct {
+	Limits       Resources
+	Reservations Resources
+}
+
+// TaskDetail is the detailed information of a task as returned by
+// `docker inspect`.
+type TaskDetail struct {
+	ID     string
+	NodeID string
+	Spec   TaskSpec
+}
+
+// NodeCapacity is the resources of a node and the resources reserved by the
+// tasks currently on it.
+type NodeCapacity struct {
+	ID           string
+	Hostname     string
+	Availability string
+	State        string
+
+	Resources Resources
+	Reserved  Resources
+}
+
+// Free returns the resources of the node that are not reserved
+func (c NodeCapacity) Free() Resources {
+	return c.Resources.Sub(c.Reserved)
+}
+
+// inspectTasks returns the detailed information of one or more tasks. This
+// must be run on a manager node.
+func (m *Manager) inspectTasks(ids ...string) ([]TaskDetail, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	cmd := fmt.Sprintf(taskInspectCommand, strings.Join(ids, " "))
+	stdout, err := m.runCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error running inspect command: %w", err)
+	}
+
+	var tasks []TaskDetail
+
+	if err := decodeJSONLines(stdout, &tasks); err != nil {
+		return nil, fmt.Errorf("error parsing json data: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// NodeCapacity returns the CPU and memory of every node in the cluster and
+// the resources reserved by the tasks that have not terminated on each node
+// (tasks without reservations reserve nothing).
+func (m *Manager) NodeCapacity() ([]NodeCapacity, error) {
+	details, err := m.currentNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, node := range details {
+		ids = append(ids, node.ID)
+	}
+
+	var tasks Tasks
+	if len(ids) > 0 {
+		tasks, err = m.getTasks(strings.Join(ids, " "))
+		if err != nil {
+			return nil, fmt.Errorf("error getting tasks: %w", err)
+		}
+	}
+
+	var active []string
+	for _, task := range tasks {
+		if !task.Terminated() {
+			active = append(active, task.ID)
+		}
+	}
+
+	taskDetails, err := m.inspectTasks(active...)
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting tasks: %w", err)
+	}
+
+	reserved := make(map[string]Resources)
+	for _, task := range taskDetails {
+		reserved[task.NodeID] = reserved[task.NodeID].Add(task.Spec.Resources.Reservations)
+	}
+
+	var capacity []NodeCapacity
+	for _, node := range details {
+		capacity = append(capacity, NodeCapacity{
+			ID:           node.ID,
+			Hostname:     node.Hostname(),
+			Availability: node.Spec.Availability,
+			State:        node.Status.State,
+			Resources:    node.Description.Resources,
+			Reserved:     reserved[node.ID],
+		})
+	}
+
+	return capacity, nil
+}
+
+// checkCapacity checks that the active and ready nodes other than the given
+// nodes (by ID or hostname) have enough free resources in total for the
+// resources reserved on the given nodes, unless DrainCapacityCheck is not
+// set. This does not account for placement constraints or how the free
+// resources are spread across nodes.
+func (m *Manager) checkCapacity(nodes []string) error {
+	if !m.config.DrainCapacityCheck {
+		return nil
+	}
+
+	capacity, err := m.NodeCapacity()
+	if err != nil {
+		return fmt.Errorf("error getting node capacity: %w", err)
+	}
+
+	var required, available Resources
+	for _, node := range capacity {
+		switch {
+		case HasString(nodes, node.ID) || HasString(nodes, node.Hostname):
+			required = required.Add(node.Reserved)
+		case node.Availability == string(AvailabilityActive) && node.State == "ready":
+			available = available.Add(node.Free())
+		}
+	}
+
+	if !available.Fits(required) {
+		return &CapacityError{Required: required, Available: available}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestNodeCapacity tests reporting the resources of each node and the
+// resources reserved by the tasks on them.
+func TestNodeCapacity(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node inspect --format "{{ json . }}" 1 2$`, swarmtest.Response{Stdout: `{"ID": "1", "Spec": {"Availability": "active"}, "Description": {"Hostname": "dm1", "Resources": {"NanoCPUs": 2000000000, "MemoryBytes": 4294967296}}, "Status": {"State": "ready"}}
+{"ID": "2", "Spec": {"Availability": "active"}, "Description": {"Hostname": "dw1", "Resources": {"NanoCPUs": 4000000000, "MemoryBytes": 8589934592}}, "Status": {"State": "ready"}}
+`})
+	runner.On(`^docker node ps`, swarmtest.Response{Stdout: `{"ID": "t1", "Name": "web.1", "Node": "dw1", "CurrentState": "Running 1 hour ago"}
+{"ID": "t2", "Name": "web.2", "Node": "dw1", "CurrentState": "Running 1 hour ago"}
+{"ID": "t3", "Name": "web.3", "Node": "dw1", "CurrentState": "Shutdown 2 hours ago"}
+`})
+	runner.On(`^docker inspect --type task`, swarmtest.Response{Stdout: `{"ID": "t1", "NodeID": "2", "Spec": {"Resources": {"Reservations": {"NanoCPUs": 500000000, "MemoryBytes": 1073741824}}}}
+{"ID": "t2", "NodeID": "2", "Spec": {"Resources": {"Reservations": {"NanoCPUs": 500000000}}}}
+`})
+
+	capacity, err := m.NodeCapacity()
+	assert.Nil(err)
+	assert.Len(capacity, 2)
+	assert.Equal(swarm.Resources{}, capacity[0].Reserved)
+	assert.Equal(swarm.Resources{NanoCPUs: 1000000000, MemoryBytes: 1073741824}, capacity[1].Reserved)
+	assert.Equal(swarm.Resources{NanoCPUs: 3000000000, MemoryBytes: 7516192768}, capacity[1].Free())
+	assert.Equal([]string{`docker inspect --type task --format "{{ json . }}" t1 t2`}, runner.Commands(`^docker inspect`))
+}
+
+// TestDrainNodesCapacity tests that nodes are not drained if the remaining
+// nodes do not have enough free resources for their tasks.
+func TestDrainNodesCapacity(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t, swarm.WithDrainCapacityCheck())
+	runner.On(`^docker node inspect --format "{{ json . }}" 1 2$`, swarmtest.Response{Stdout: `{"ID": "1", "Spec": {"Availability": "active"}, "Description": {"Hostname": "dm1", "Resources": {"NanoCPUs": 1000000000, "MemoryBytes": 4294967296}}, "Status": {"State": "ready"}}
+{"ID": "2", "Spec": {"Availability": "active"}, "Description": {"Hostname": "dw1", "Resources": {"NanoCPUs": 4000000000, "MemoryBytes": 8589934592}}, "Status": {"State": "ready"}}
+`})
+	runner.On(`^docker node ps`, swarmtest.Response{Stdout: `{"ID": "t1", "Name": "web.1", "Node": "dw1", "CurrentState": "Running 1 hour ago"}
+`})
+	runner.On(`^docker inspect --type task`, swarmtest.Response{Stdout: `{"ID": "t1", "NodeID": "2", "Spec": {"Resources": {"Reservations": {"NanoCPUs": 2000000000}}}}
+`})
+	runner.On(`^docker node update`)
+
+	_, err := m.DrainNodes([]string{"dw1"})
+	var capacityErr *swarm.CapacityError
+	assert.ErrorAs(err, &capacityErr)
+	assert.Equal(int64(2000000000), capacityErr.Required.NanoCPUs)
+	assert.Empty(runner.Commands(`^docker node update`))
+}
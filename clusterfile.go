@@ -21,10 +21,19 @@
 package swarm
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -44,16 +53,66 @@ const (
 	// `key1=value1&key2=value2&key3&key4`
 	// (This uses the URL Query String format).
 	LabelsTag = "labels"
+
+	// EngineLabelsTag is the tag for Docker Engine labels which are
+	// configured in the Docker daemon (`daemon.json`) rather than the
+	// Swarm. Engine labels cannot be applied with `docker node update`
+	// and are ignored with a warning. Use LabelsTag for node labels.
+	EngineLabelsTag = "engine_labels"
+
+	// AvailabilityTag is the tag for the availability a node is set to
+	// after it joins the swarm, one of "active" (the default), "drain" or
+	// "pause" (e.g: "drain" for nodes held as reserved capacity).
+	AvailabilityTag = "availability"
+
+	// DescriptionTag is the tag for a free-form description of a node
+	// (e.g: human notes about its purpose) which is applied to the node as
+	// the DescriptionLabel node label.
+	DescriptionTag = "description"
+
+	// DescriptionLabel is the reserved node label a node's DescriptionTag is
+	// applied as.
+	DescriptionLabel = "com.aucloud.swarm.description"
 )
 
+// Role is the Swarm role of a node, either ManagerRole or WorkerRole
+type Role string
+
+// ParseRole parses s (e.g: the RoleTag of a node) into a Role
+func ParseRole(s string) (Role, error) {
+	switch s {
+	case ManagerRole, WorkerRole:
+		return Role(s), nil
+	default:
+		return "", fmt.Errorf("invalid role %q (expected %s or %s)", s, ManagerRole, WorkerRole)
+	}
+}
+
+func (r Role) String() string {
+	return string(r)
+}
+
+// knownTags are the tags understood by the Clusterfile
+var knownTags = []string{RoleTag, LabelsTag, EngineLabelsTag, AvailabilityTag, DescriptionTag}
+
 // VMNode represents a single VM Node and at a bare minimum contains the
 // node's hostname, private and public ip addresses as well as a list of tags
 // used to label the nodes for different purposes such as Manager ndoes.
+//
+// DataPathAddress is optional and if set is the address used for overlay
+// network (data path) traffic on hosts with separate management and data
+// networks.
+//
+// AdvertiseInterface is an alternative to PrivateAddress naming the network
+// interface (e.g: eth1) Swarm advertises and listens on for hosts whose
+// addresses are assigned dynamically. Exactly one of the two must be set.
 type VMNode struct {
-	Hostname       string            `json:"hostname"`
-	PublicAddress  string            `json:"public_address"`
-	PrivateAddress string            `json:"private_address"`
-	Tags           map[string]string `json:"tags"`
+	Hostname           string            `json:"hostname" yaml:"hostname"`
+	PublicAddress      string            `json:"public_address" yaml:"public_address"`
+	PrivateAddress     string            `json:"private_address" yaml:"private_address"`
+	AdvertiseInterface string            `json:"advertise_interface" yaml:"advertise_interface"`
+	DataPathAddress    string            `json:"data_path_address" yaml:"data_path_address"`
+	Tags               map[string]string `json:"tags" yaml:"tags"`
 }
 
 func (vm VMNode) Stirng() string {
@@ -63,6 +122,40 @@ func (vm VMNode) Stirng() string {
 	)
 }
 
+// AdvertiseAddr returns the address or interface Swarm advertises and
+// listens on for the node.
+func (vm VMNode) AdvertiseAddr() string {
+	if vm.PrivateAddress != "" {
+		return vm.PrivateAddress
+	}
+	return vm.AdvertiseInterface
+}
+
+// Role returns the Swarm role of the node from its RoleTag or an empty Role
+// if the tag is missing or not a valid role.
+func (vm VMNode) Role() Role {
+	role, _ := ParseRole(vm.GetTag(RoleTag))
+	return role
+}
+
+// Labels returns the labels of the node parsed from its LabelsTag along with
+// its DescriptionTag as the DescriptionLabel if it has one.
+func (vm VMNode) Labels() (url.Values, error) {
+	labels, err := ParseLabels(vm.GetTag(LabelsTag))
+	if err != nil {
+		return nil, err
+	}
+
+	if description := strings.TrimSpace(vm.GetTag(DescriptionTag)); description != "" {
+		if labels == nil {
+			labels = make(url.Values)
+		}
+		labels.Set(DescriptionLabel, description)
+	}
+
+	return labels, nil
+}
+
 func (vm VMNode) GetTag(name string) string {
 	return vm.Tags[name]
 }
@@ -86,6 +179,28 @@ func (vms VMNodes) FilterByTag(name, value string) VMNodes {
 	return res
 }
 
+// WithoutRole returns the nodes whose RoleTag is missing or is not a valid
+// Role and so are neither managers nor workers
+func (vms VMNodes) WithoutRole() VMNodes {
+	var res VMNodes
+
+	for _, vm := range vms {
+		if vm.Role() == "" {
+			res = append(res, vm)
+		}
+	}
+
+	return res
+}
+
+// Counts returns the number of managers and workers by their RoleTag
+func (vms VMNodes) Counts() NodeCounts {
+	return NodeCounts{
+		Managers: len(vms.FilterByTag(RoleTag, ManagerRole)),
+		Workers:  len(vms.FilterByTag(RoleTag, WorkerRole)),
+	}
+}
+
 func (vms VMNodes) FilterByPrivateAddress(address string) VMNodes {
 	var res VMNodes
 
@@ -110,23 +225,73 @@ func (vms VMNodes) FilterByPublicAddress(address string) VMNodes {
 	return res
 }
 
+func (vms VMNodes) FilterByHostname(hostname string) VMNodes {
+	var res VMNodes
+
+	for _, vm := range vms {
+		if vm.Hostname == hostname {
+			res = append(res, vm)
+		}
+	}
+
+	return res
+}
+
+// ClusterfileVersion is the current version of the Clusterfile format.
+// Clusterfiles without a version are assumed to be the current version.
+const ClusterfileVersion = 1
+
 // Clusterfile represents a set of VMNode(s) as a collection of VM(s)
 // along with the region, enviornment, cluster and domain those nodes
 // belong to.
+//
+// Version is the version of the Clusterfile format (see ClusterfileVersion).
 type Clusterfile struct {
-	Region      string `json:"region"`
-	Environment string `json:"environment"`
-	Cluster     string `json:"cluster"`
-	Domain      string `json:"domain"`
+	Version int `json:"version,omitempty" yaml:"version,omitempty"`
 
-	Nodes VMNodes `json:"nodes"`
+	Region      string `json:"region" yaml:"region"`
+	Environment string `json:"environment" yaml:"environment"`
+	Cluster     string `json:"cluster" yaml:"cluster"`
+	Domain      string `json:"domain" yaml:"domain"`
+
+	Nodes VMNodes `json:"nodes" yaml:"nodes"`
+}
+
+// checkVersion defaults the Clusterfile's version to ClusterfileVersion and
+// returns an error for versions that are not supported (e.g: written for a
+// newer release).
+func (cf *Clusterfile) checkVersion() error {
+	if cf.Version == 0 {
+		cf.Version = ClusterfileVersion
+	}
+
+	if cf.Version != ClusterfileVersion {
+		return fmt.Errorf("unsupported clusterfile version %d (expected %d)", cf.Version, ClusterfileVersion)
+	}
+
+	return nil
 }
 
 func (cf *Clusterfile) Validate() error {
 	var managers int
 
 	for _, node := range cf.Nodes {
-		if node.HasTag(RoleTag, ManagerRole) {
+		if (node.PrivateAddress == "") == (node.AdvertiseInterface == "") {
+			return fmt.Errorf(
+				"node %s must have exactly one of private_address or advertise_interface",
+				node.Hostname,
+			)
+		}
+		if availability := node.GetTag(AvailabilityTag); availability != "" {
+			if _, err := ParseAvailability(availability); err != nil {
+				return fmt.Errorf("node %s has %s", node.Hostname, err)
+			}
+		}
+		role, err := ParseRole(node.GetTag(RoleTag))
+		if err != nil {
+			return fmt.Errorf("node %s has %s", node.Hostname, err)
+		}
+		if role == ManagerRole {
 			managers++
 		}
 	}
@@ -134,27 +299,240 @@ func (cf *Clusterfile) Validate() error {
 	// hard-coded based on knowledge of Raft consensus algorithms
 	// where you would typically have 3 or 5 manager nodes to form
 	// a quorum.
-	if managers == 3 || managers == 5 {
-		return nil
+	return checkValidManagerCount(managers)
+}
+
+// MergeClusterfiles merges one or more Clusterfiles in order so a base
+// Clusterfile can be layered with overlays (e.g: per environment). Later
+// Clusterfiles take precedence:
+//
+//   - Non-empty region, environment, cluster and domain override earlier ones
+//   - Nodes with a new hostname are appended
+//   - Nodes with an existing hostname are merged with the earlier node: its
+//     non-empty addresses override the earlier node's and its tags are merged
+//     with the earlier node's tags, overriding tags with the same name
+//
+// An error is returned if any node has no hostname.
+func MergeClusterfiles(clusterFiles ...Clusterfile) (Clusterfile, error) {
+	var res Clusterfile
+
+	index := make(map[string]int)
+
+	for _, cf := range clusterFiles {
+		if cf.Version != 0 {
+			res.Version = cf.Version
+		}
+		if cf.Region != "" {
+			res.Region = cf.Region
+		}
+		if cf.Environment != "" {
+			res.Environment = cf.Environment
+		}
+		if cf.Cluster != "" {
+			res.Cluster = cf.Cluster
+		}
+		if cf.Domain != "" {
+			res.Domain = cf.Domain
+		}
+
+		for _, node := range cf.Nodes {
+			if node.Hostname == "" {
+				return Clusterfile{}, fmt.Errorf("error node with public address %q has no hostname", node.PublicAddress)
+			}
+
+			i, ok := index[node.Hostname]
+			if !ok {
+				index[node.Hostname] = len(res.Nodes)
+				res.Nodes = append(res.Nodes, mergeNode(VMNode{Hostname: node.Hostname}, node))
+				continue
+			}
+
+			res.Nodes[i] = mergeNode(res.Nodes[i], node)
+		}
 	}
 
-	return fmt.Errorf("number of managers should be 3 or 5 not %d", managers)
+	return res, nil
+}
+
+// mergeNode returns base with the non-empty fields and tags of overlay
+func mergeNode(base, overlay VMNode) VMNode {
+	if overlay.PublicAddress != "" {
+		base.PublicAddress = overlay.PublicAddress
+	}
+	if overlay.PrivateAddress != "" {
+		base.PrivateAddress = overlay.PrivateAddress
+	}
+	if overlay.AdvertiseInterface != "" {
+		base.AdvertiseInterface = overlay.AdvertiseInterface
+	}
+	if overlay.DataPathAddress != "" {
+		base.DataPathAddress = overlay.DataPathAddress
+	}
+
+	tags := make(map[string]string)
+	for name, value := range base.Tags {
+		tags[name] = value
+	}
+	for name, value := range overlay.Tags {
+		tags[name] = value
+	}
+	if len(tags) > 0 {
+		base.Tags = tags
+	}
+
+	return base
 }
 
 // ReadClusterfile reads a `Clusterfile` or `Clusterfile.json` from an
 // `io.Reader` such as an open file or stadnard input and parses it into
-// a `ClusterInfo` struct.
+// a `ClusterInfo` struct. The Clusterfile may be either JSON (e.g: from
+// `terraform output -json`) or YAML (e.g: edited by hand) and is parsed as
+// JSON if it starts with `{` and as YAML otherwise. Clusterfiles of a
+// version other than ClusterfileVersion are rejected.
 func ReadClusterfile(r io.Reader) (Clusterfile, error) {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return Clusterfile{}, fmt.Errorf("error reading from reader: %w", err)
 	}
 
+	if len(bytes.TrimSpace(data)) == 0 {
+		return Clusterfile{}, fmt.Errorf("error parsing clusterfile: empty input")
+	}
+
+	var clusterFile Clusterfile
+
+	if isJSON(data) {
+		if err := json.Unmarshal(data, &clusterFile); err != nil {
+			return Clusterfile{}, fmt.Errorf("error parsing json: %s", err)
+		}
+	} else if err := yaml.Unmarshal(data, &clusterFile); err != nil {
+		return Clusterfile{}, fmt.Errorf("error parsing clusterfile as json or yaml: %s", err)
+	}
+
+	if err := clusterFile.checkVersion(); err != nil {
+		return Clusterfile{}, fmt.Errorf("error reading clusterfile: %w", err)
+	}
+
+	return clusterFile, nil
+}
+
+// isJSON returns true if data looks like a JSON object
+func isJSON(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// interpolateRegex matches an escaped `$$` or a `${VAR}` reference
+var interpolateRegex = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Interpolate substitutes every `${VAR}` in data with the value of the
+// environment variable VAR and every `$$` with a literal `$`. Any other `$`
+// is left as is. Undefined variables are substituted with an empty string
+// unless strict is true in which case an error naming them is returned.
+func Interpolate(data []byte, strict bool) ([]byte, error) {
+	return interpolate(data, os.LookupEnv, strict)
+}
+
+func interpolate(data []byte, lookup func(string) (string, bool), strict bool) ([]byte, error) {
+	var undefined []string
+
+	res := interpolateRegex.ReplaceAllFunc(data, func(match []byte) []byte {
+		if string(match) == "$$" {
+			return []byte("$")
+		}
+
+		name := string(match[2 : len(match)-1])
+		value, ok := lookup(name)
+		if !ok && !HasString(undefined, name) {
+			undefined = append(undefined, name)
+		}
+		return []byte(value)
+	})
+
+	if strict && len(undefined) > 0 {
+		sort.Strings(undefined)
+		return nil, fmt.Errorf("error undefined variables: %s", strings.Join(undefined, ", "))
+	}
+
+	return res, nil
+}
+
+// ReadClusterfileEnv is like ReadClusterfile but first substitutes
+// environment variables in the Clusterfile (see Interpolate) so a single
+// templated Clusterfile can be used for different environments. The
+// substitution is done on the raw Clusterfile before it is parsed.
+func ReadClusterfileEnv(r io.Reader, strict bool) (Clusterfile, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Clusterfile{}, fmt.Errorf("error reading from reader: %w", err)
+	}
+
+	data, err = Interpolate(data, strict)
+	if err != nil {
+		return Clusterfile{}, fmt.Errorf("error interpolating clusterfile: %w", err)
+	}
+
+	return ReadClusterfile(bytes.NewReader(data))
+}
+
+// ReadClusterfileStrict is like ReadClusterfile but returns an error if the
+// Clusterfile contains any unknown fields or tags (e.g: a misspelt `lables`
+// tag) rather than silently ignoring them.
+func ReadClusterfileStrict(r io.Reader) (Clusterfile, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Clusterfile{}, fmt.Errorf("error reading from reader: %w", err)
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return Clusterfile{}, fmt.Errorf("error parsing clusterfile: empty input")
+	}
+
 	var clusterFile Clusterfile
 
-	if err := json.Unmarshal(data, &clusterFile); err != nil {
-		return Clusterfile{}, fmt.Errorf("error parsing json: %s", err)
+	if isJSON(data) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&clusterFile); err != nil {
+			return Clusterfile{}, fmt.Errorf("error parsing json: %s", err)
+		}
+	} else if err := yaml.UnmarshalStrict(data, &clusterFile); err != nil {
+		return Clusterfile{}, fmt.Errorf("error parsing clusterfile as json or yaml: %s", err)
+	}
+
+	for _, node := range clusterFile.Nodes {
+		for name := range node.Tags {
+			if !HasString(knownTags, name) {
+				return Clusterfile{}, fmt.Errorf("error unknown tag %q for node %s", name, node.Hostname)
+			}
+		}
+	}
+
+	if err := clusterFile.checkVersion(); err != nil {
+		return Clusterfile{}, fmt.Errorf("error reading clusterfile: %w", err)
 	}
 
 	return clusterFile, nil
 }
+
+// ReadClusterfileContext is like ReadClusterfile but returns an error if
+// ctx is done before the Clusterfile has been read (e.g: standard input was
+// never piped). The read itself cannot be interrupted and is abandoned.
+func ReadClusterfileContext(ctx context.Context, r io.Reader) (Clusterfile, error) {
+	type result struct {
+		clusterFile Clusterfile
+		err         error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		clusterFile, err := ReadClusterfile(r)
+		done <- result{clusterFile, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.clusterFile, res.err
+	case <-ctx.Done():
+		return Clusterfile{}, fmt.Errorf("error reading from reader: %w", ctx.Err())
+	}
+}
@@ -22,7 +22,10 @@ package swarm
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -50,6 +53,24 @@ const testClusterfile = `{
 }
 `
 
+const testClusterfileYAML = `
+region: local
+environment: test
+cluster: c1
+domain: localdomain
+nodes:
+  - hostname: dm1
+    public_address: 10.0.0.1
+    private_address: 172.16.0.1
+    tags:
+      role: manager
+  - hostname: dw1
+    public_address: 10.0.0.2
+    private_address: 172.16.0.2
+    tags:
+      role: worker
+`
+
 // TestReadClusterfile tests the `ReadClusterfiel` function that reads and
 // parses a valid `Clusterfile` or `Clusterfile.json`.
 func TestReadClusterfile(t *testing.T) {
@@ -58,6 +79,7 @@ func TestReadClusterfile(t *testing.T) {
 	actual, err := ReadClusterfile(bytes.NewBufferString(testClusterfile))
 	assert.Nil(err)
 	expected := Clusterfile{
+		Version:     ClusterfileVersion,
 		Region:      "local",
 		Environment: "test",
 		Cluster:     "c1",
@@ -82,6 +104,40 @@ func TestReadClusterfile(t *testing.T) {
 		},
 	}
 	assert.Equal(expected, actual)
+
+	actual, err = ReadClusterfile(bytes.NewBufferString(testClusterfileYAML))
+	assert.Nil(err)
+	assert.Equal(expected, actual)
+
+	_, err = ReadClusterfile(bytes.NewBufferString(`{"nodes": [`))
+	assert.Error(err)
+
+	_, err = ReadClusterfile(bytes.NewBufferString("not a clusterfile"))
+	assert.Error(err)
+
+	_, err = ReadClusterfile(bytes.NewBufferString("\n"))
+	assert.Error(err)
+}
+
+// TestReadClusterfileVersion tests that Clusterfiles default to the current
+// version and other versions are rejected.
+func TestReadClusterfileVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	actual, err := ReadClusterfile(bytes.NewBufferString(`{"version": 1, "nodes": []}`))
+	assert.Nil(err)
+	assert.Equal(ClusterfileVersion, actual.Version)
+
+	actual, err = ReadClusterfile(bytes.NewBufferString("nodes: []\n"))
+	assert.Nil(err)
+	assert.Equal(ClusterfileVersion, actual.Version)
+
+	_, err = ReadClusterfile(bytes.NewBufferString(`{"version": 99, "nodes": []}`))
+	assert.Error(err)
+	assert.Contains(err.Error(), "unsupported clusterfile version 99")
+
+	_, err = ReadClusterfileStrict(bytes.NewBufferString(`{"version": -1, "nodes": []}`))
+	assert.Error(err)
 }
 
 // TestFilterByTags tests the `VMNodes.FilterByTags()` functionality to ensure
@@ -122,3 +178,217 @@ func TestFilterByPublicAddress(t *testing.T) {
 	assert.Len(vms, 1)
 	assert.Equal(vms[0].Hostname, "dm1")
 }
+
+// TestReadClusterfileContext tests that reading a Clusterfile from a reader
+// that never produces any data fails once the context is done.
+func TestReadClusterfileContext(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	actual, err := ReadClusterfileContext(ctx, bytes.NewBufferString(testClusterfile))
+	assert.Nil(err)
+	assert.Len(actual.Nodes, 2)
+
+	r, w := io.Pipe()
+	defer w.Close()
+
+	_, err = ReadClusterfileContext(ctx, r)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+}
+
+// TestValidateAdvertiseAddr tests that each node must have exactly one of a
+// private address or an advertise interface.
+func TestValidateAdvertiseAddr(t *testing.T) {
+	assert := assert.New(t)
+
+	cf := Clusterfile{
+		Nodes: VMNodes{
+			{Hostname: "dm1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+			{Hostname: "dm2", AdvertiseInterface: "eth1", Tags: map[string]string{"role": "manager"}},
+			{Hostname: "dm3", PrivateAddress: "172.16.0.3", Tags: map[string]string{"role": "manager"}},
+		},
+	}
+	assert.Nil(cf.Validate())
+
+	cf.Nodes[0].AdvertiseInterface = "eth1"
+	assert.Error(cf.Validate())
+
+	cf.Nodes[0].PrivateAddress = ""
+	cf.Nodes[0].AdvertiseInterface = ""
+	assert.Error(cf.Validate())
+}
+
+// TestReadClusterfileStrict tests that unknown fields and tags are rejected
+// when parsing strictly in either JSON or YAML.
+func TestReadClusterfileStrict(t *testing.T) {
+	assert := assert.New(t)
+
+	actual, err := ReadClusterfileStrict(bytes.NewBufferString(testClusterfile))
+	assert.Nil(err)
+	assert.Len(actual.Nodes, 2)
+
+	_, err = ReadClusterfileStrict(bytes.NewBufferString(`{"nodes": [{"hostname": "dm1", "public_addr": "10.0.0.1"}]}`))
+	assert.Error(err)
+
+	_, err = ReadClusterfileStrict(bytes.NewBufferString(`{"nodes": [{"hostname": "dm1", "tags": {"lables": "zone=a"}}]}`))
+	assert.Error(err)
+	assert.Contains(err.Error(), "lables")
+
+	actual, err = ReadClusterfileStrict(bytes.NewBufferString("nodes:\n  - hostname: dm1\n    public_address: 10.0.0.1\n"))
+	assert.Nil(err)
+	assert.Equal("10.0.0.1", actual.Nodes[0].PublicAddress)
+
+	_, err = ReadClusterfileStrict(bytes.NewBufferString("nodes:\n  - hostname: dm1\n    public_addr: 10.0.0.1\n"))
+	assert.Error(err)
+	assert.Contains(err.Error(), "public_addr")
+
+	_, err = ReadClusterfileStrict(bytes.NewBufferString("nodes:\n  - hostname: dm1\n    tags:\n      lables: zone=a\n"))
+	assert.Error(err)
+	assert.Contains(err.Error(), "lables")
+}
+
+// TestValidateAvailability tests that the availability tag must be one of
+// active, drain or pause.
+func TestValidateAvailability(t *testing.T) {
+	assert := assert.New(t)
+
+	cf := Clusterfile{
+		Nodes: VMNodes{
+			{Hostname: "dm1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+			{Hostname: "dm2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "manager"}},
+			{Hostname: "dm3", PrivateAddress: "172.16.0.3", Tags: map[string]string{"role": "manager"}},
+			{Hostname: "dw1", PrivateAddress: "172.16.0.4", Tags: map[string]string{"role": "worker", "availability": "drain"}},
+		},
+	}
+	assert.Nil(cf.Validate())
+
+	cf.Nodes[3].Tags["availability"] = "drained"
+	assert.Error(cf.Validate())
+}
+
+// TestValidateRole tests that every node must have a role tag of manager or
+// worker.
+func TestValidateRole(t *testing.T) {
+	assert := assert.New(t)
+
+	cf := Clusterfile{
+		Nodes: VMNodes{
+			{Hostname: "dm1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+			{Hostname: "dm2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "manager"}},
+			{Hostname: "dm3", PrivateAddress: "172.16.0.3", Tags: map[string]string{"role": "manager"}},
+			{Hostname: "dw1", PrivateAddress: "172.16.0.4", Tags: map[string]string{"role": "worker", "labels": "zone=a"}},
+		},
+	}
+	assert.Nil(cf.Validate())
+	assert.Equal(Role(ManagerRole), cf.Nodes[0].Role())
+	assert.Equal(Role(WorkerRole), cf.Nodes[3].Role())
+
+	labels, err := cf.Nodes[3].Labels()
+	assert.Nil(err)
+	assert.Equal("a", labels.Get("zone"))
+
+	cf.Nodes[3].Tags["role"] = "wroker"
+	assert.Equal(Role(""), cf.Nodes[3].Role())
+	err = cf.Validate()
+	assert.Error(err)
+	assert.Contains(err.Error(), "dw1")
+
+	delete(cf.Nodes[3].Tags, "role")
+	assert.Error(cf.Validate())
+}
+
+// TestValidateManagerCount tests that a Clusterfile must have 3 or 5
+// managers.
+func TestValidateManagerCount(t *testing.T) {
+	assert := assert.New(t)
+
+	cf := Clusterfile{
+		Nodes: VMNodes{
+			{Hostname: "dm1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+			{Hostname: "dm2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "manager"}},
+			{Hostname: "dw1", PrivateAddress: "172.16.0.3", Tags: map[string]string{"role": "worker"}},
+		},
+	}
+
+	err := cf.Validate()
+	assert.ErrorIs(err, ErrInvalidManagerCount)
+
+	var countErr *InvalidManagerCountError
+	if assert.ErrorAs(err, &countErr) {
+		assert.Equal(2, countErr.Got)
+		assert.Equal([]int{3, 5}, countErr.Expected)
+	}
+	assert.Equal("expected 3 or 5 managers but got 2", err.Error())
+}
+
+// TestMergeClusterfiles tests merging a base Clusterfile with an overlay.
+func TestMergeClusterfiles(t *testing.T) {
+	assert := assert.New(t)
+
+	base := Clusterfile{
+		Region:  "local",
+		Cluster: "c1",
+		Nodes: VMNodes{
+			{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager", "labels": "zone=a"}},
+			{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+		},
+	}
+	overlay := Clusterfile{
+		Environment: "prod",
+		Cluster:     "c2",
+		Nodes: VMNodes{
+			{Hostname: "dw1", DataPathAddress: "192.168.0.2", Tags: map[string]string{"labels": "gpu"}},
+			{Hostname: "dw2", PublicAddress: "10.0.0.3", PrivateAddress: "172.16.0.3", Tags: map[string]string{"role": "worker"}},
+		},
+	}
+
+	actual, err := MergeClusterfiles(base, overlay)
+	assert.Nil(err)
+	assert.Equal(Clusterfile{
+		Region:      "local",
+		Environment: "prod",
+		Cluster:     "c2",
+		Nodes: VMNodes{
+			{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager", "labels": "zone=a"}},
+			{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", DataPathAddress: "192.168.0.2", Tags: map[string]string{"role": "worker", "labels": "gpu"}},
+			{Hostname: "dw2", PublicAddress: "10.0.0.3", PrivateAddress: "172.16.0.3", Tags: map[string]string{"role": "worker"}},
+		},
+	}, actual)
+
+	// The base is not modified
+	assert.Equal(map[string]string{"role": "worker"}, base.Nodes[1].Tags)
+
+	_, err = MergeClusterfiles(Clusterfile{Nodes: VMNodes{{PublicAddress: "10.0.0.1"}}})
+	assert.Error(err)
+}
+
+// TestReadClusterfileEnv tests substituting environment variables in a
+// Clusterfile before it is parsed.
+func TestReadClusterfileEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("SWARM_TEST_ENVIRONMENT", "prod")
+	t.Setenv("SWARM_TEST_ADDRESS", "10.0.0.1")
+
+	cf := `{
+  "environment": "${SWARM_TEST_ENVIRONMENT}",
+  "domain": "$HOME$$",
+  "nodes": [{
+    "hostname": "dm1",
+    "public_address": "${SWARM_TEST_ADDRESS}",
+    "private_address": "${SWARM_TEST_UNDEFINED}"
+  }]
+}`
+
+	actual, err := ReadClusterfileEnv(bytes.NewBufferString(cf), false)
+	assert.Nil(err)
+	assert.Equal("prod", actual.Environment)
+	assert.Equal("$HOME$", actual.Domain)
+	assert.Equal("10.0.0.1", actual.Nodes[0].PublicAddress)
+	assert.Equal("", actual.Nodes[0].PrivateAddress)
+
+	_, err = ReadClusterfileEnv(bytes.NewBufferString(cf), true)
+	assert.EqualError(err, "error interpolating clusterfile: error undefined variables: SWARM_TEST_UNDEFINED")
+}
@@ -39,15 +39,18 @@ func init() {
 }
 
 var createCmd = &cobra.Command{
-	Use:     "create",
+	Use:     "create <Clusterfile> [<Clusterfile>...]",
 	Aliases: []string{},
 	Short:   "Creates a new Swarm Cluster",
 	Long: `This command uses a Clusterfile that describes a new VM Cluster
 of nodes to create a new Docker Swarm Cluster. The Clusterfile is expected to
 have information about the region, enviornment, cluaster and a list of nodes
 along with their public and private ip address. Each node must also have a set
-of labels that are used to assign nodes as managers and others as workers.`,
-	Args: cobra.ExactArgs(1),
+of labels that are used to assign nodes as managers and others as workers.
+
+More than one Clusterfile may be given in which case they are merged in order
+with later Clusterfiles overriding earlier ones (nodes are merged by hostname).`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		force := viper.GetBool("force-single-manager-cluster")
 		internal.Create(manager, args, force)
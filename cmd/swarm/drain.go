@@ -21,23 +21,48 @@
 package main
 
 import (
+	"os"
+
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/aucloud/go-swarm/internal"
 )
 
 func init() {
+	drainCmd.Flags().Bool(
+		"skip-quorum-check", false,
+		"Drain even if a majority of managers are not reachable",
+	)
+	viper.BindPFlag("skip-quorum-check", drainCmd.Flags().Lookup("skip-quorum-check"))
+	viper.SetDefault("skip-quorum-check", false)
+
+	drainCmd.Flags().StringP(
+		"clusterfile", "c", "",
+		"Drain the nodes in the Clusterfile (- for standard input)",
+	)
+	drainCmd.Flags().StringP(
+		"role", "r", "",
+		"Only drain the nodes in the Clusterfile with the role (manager or worker)",
+	)
+
 	RootCmd.AddCommand(drainCmd)
 }
 
 var drainCmd = &cobra.Command{
-	Use:     "drain",
+	Use:     "drain [<hostname>...]",
 	Aliases: []string{},
 	Short:   "Drains one or more nodes in an existing Swarm Cluster",
 	Long: `This command drains one or more nodes from an existing Swarm Cluster
-and waits for tasks to be shutdown on those nodes before returning.`,
-	Args: cobra.ExactArgs(1),
+one at a time and waits for tasks to be shutdown on each node before draining
+the next. Nodes are given by their hostname and/or read from a Clusterfile
+(optionally only those with a given role).
+
+The exit status is non-zero if any node could not be drained.`,
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		internal.Drain(manager, args)
+		clusterFile, _ := cmd.Flags().GetString("clusterfile")
+		role, _ := cmd.Flags().GetString("role")
+		os.Exit(internal.Drain(manager, args, clusterFile, role))
 	},
 }
@@ -104,7 +104,18 @@ Supported functions include:
 			switcher = sshSwitcher
 		}
 
-		if manager, err = swarm.NewManager(switcher); err != nil {
+		var options []swarm.Option
+		if viper.GetBool("skip-quorum-check") {
+			options = append(options, swarm.WithSkipQuorumCheck())
+		}
+		if path := viper.GetString("docker-path"); path != "" {
+			options = append(options, swarm.WithDockerPath(path))
+		}
+		if prefix := viper.GetString("command-prefix"); prefix != "" {
+			options = append(options, swarm.WithCommandPrefix(prefix))
+		}
+
+		if manager, err = swarm.NewManager(switcher, options...); err != nil {
 			fmt.Fprintf(os.Stderr, "error creating manager: %s\n", err)
 			os.Exit(-1)
 		}
@@ -164,6 +175,19 @@ func init() {
 		"Path to Docker UNIX Socket",
 	)
 
+	RootCmd.PersistentFlags().String(
+		"docker-path", "",
+		"Path to the docker binary on nodes (default docker from the PATH)",
+	)
+
+	RootCmd.PersistentFlags().String(
+		"command-prefix", "",
+		"Prefix for commands run on nodes (e.g: sudo)",
+	)
+
+	viper.BindPFlag("docker-path", RootCmd.PersistentFlags().Lookup("docker-path"))
+	viper.BindPFlag("command-prefix", RootCmd.PersistentFlags().Lookup("command-prefix"))
+
 	viper.BindPFlag("use-local", RootCmd.PersistentFlags().Lookup("use-local"))
 	viper.SetDefault("use-local", false)
 
@@ -31,15 +31,18 @@ func init() {
 }
 
 var updateCmd = &cobra.Command{
-	Use:     "update",
+	Use:     "update <Clusterfile> [<Clusterfile>...]",
 	Aliases: []string{},
 	Short:   "Updates an existing Swarm Cluster",
 	Long: `This command uses a Clusterfile that describes the number of
 and types of nodes that should exist in the Swarm Cluster. If there are
 nodes that are missing from the cluster that should be new managers or
 workers, they are added. Any that should be removed are drained and
-removed from the cluster gracefully.`,
-	Args: cobra.ExactArgs(1),
+removed from the cluster gracefully.
+
+More than one Clusterfile may be given in which case they are merged in order
+with later Clusterfiles overriding earlier ones (nodes are merged by hostname).`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		internal.Update(manager, args)
 	},
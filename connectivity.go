@@ -0,0 +1,86 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"sort"
+)
+
+// NodeConnectivity is the result of testing the connection to a node. Err is
+// a *ConnectionError if the node could not be reached or its Docker daemon is
+// not responding.
+type NodeConnectivity struct {
+	Address string
+	Version string
+	Err     error
+}
+
+// OK returns true if the node was reached and its Docker daemon responded
+func (c NodeConnectivity) OK() bool {
+	return c.Err == nil
+}
+
+// ConnectivityReport is the result of testing the connection to every node
+// of a Clusterfile keyed by hostname.
+type ConnectivityReport map[string]NodeConnectivity
+
+// Failed returns the sorted hostnames of the nodes that could not be reached
+func (r ConnectivityReport) Failed() []string {
+	var hostnames []string
+	for hostname, c := range r {
+		if !c.OK() {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	sort.Strings(hostnames)
+	return hostnames
+}
+
+// TestConnectivity switches to every node in vms and checks that its Docker
+// daemon is responding (see Ping) without making any changes. Every node is
+// tested even if some fail and a MultiError of *ConnectionError is returned
+// along with the report if any could not be reached. The Manager is switched
+// back to the node that was current before.
+func (m *Manager) TestConnectivity(vms VMNodes) (ConnectivityReport, error) {
+	report := make(ConnectivityReport)
+
+	var errs MultiError
+	err := m.preserveNode(func() error {
+		for _, vm := range vms {
+			c := NodeConnectivity{Address: vm.PublicAddress}
+			c.Version, c.Err = m.Ping(vm.PublicAddress)
+			if c.Err != nil {
+				errs = append(errs, c.Err)
+			}
+			report[vm.Hostname] = c
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if len(errs) > 0 {
+		return report, errs
+	}
+
+	return report, nil
+}
@@ -0,0 +1,70 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestConnectivity tests that every node is tested and failures are
+// reported for each node that cannot be reached.
+func TestConnectivity(t *testing.T) {
+	assert := assert.New(t)
+
+	runner := swarmtest.NewFakeRunner()
+	runner.OnNode("10.0.0.1", `^docker version`, swarmtest.Response{Stdout: "20.10.12\n"})
+	runner.OnNode("10.0.0.2", `^docker version`, swarmtest.Response{Stderr: "Cannot connect to the Docker daemon", ExitCode: 1})
+
+	switcher := swarmtest.NewFakeSwitcher(runner)
+	switcher.FailSwitch("10.0.0.3", errors.New("permission denied (publickey)"))
+
+	m, err := swarm.NewManager(switcher)
+	assert.Nil(err)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1"},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2"},
+		{Hostname: "dw2", PublicAddress: "10.0.0.3"},
+	}
+
+	report, err := m.TestConnectivity(vms)
+	var errs swarm.MultiError
+	assert.ErrorAs(err, &errs)
+	assert.Len(errs, 2)
+	assert.Len(report, 3)
+	assert.True(report["dm1"].OK())
+	assert.Equal("20.10.12", report["dm1"].Version)
+	assert.Equal([]string{"dw1", "dw2"}, report.Failed())
+
+	var connErr *swarm.ConnectionError
+	assert.ErrorAs(report["dw2"].Err, &connErr)
+	assert.Equal("10.0.0.3", connErr.Node)
+
+	report, err = m.TestConnectivity(vms[:1])
+	assert.Nil(err)
+	assert.Empty(report.Failed())
+}
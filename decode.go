@@ -0,0 +1,102 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxDecodeSample is the maximum number of bytes of a line that could not
+// be decoded included in a DecodeError
+const maxDecodeSample = 200
+
+// decodeJSONLines decodes r, the output of a `docker ... --format "{{ json
+// . }}"` command with one JSON object per line, into the slice pointed to by
+// ptrToSlice. Blank lines are skipped and lines that are not JSON objects
+// (e.g: deprecation warnings printed by newer Docker CLIs) are logged and
+// skipped. A *DecodeError with the line number and a sample of the line is
+// returned if a JSON object cannot be decoded.
+func decodeJSONLines(r io.Reader, ptrToSlice interface{}) error {
+	v := reflect.ValueOf(ptrToSlice)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("error expected pointer to slice not %T", ptrToSlice)
+	}
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+
+	return scanJSONLines(r, func(n int, line []byte) error {
+		elem := reflect.New(elemType)
+		if err := json.Unmarshal(line, elem.Interface()); err != nil {
+			return &DecodeError{Line: n, Sample: truncate(string(line), maxDecodeSample), Err: err}
+		}
+		slice.Set(reflect.Append(slice, elem.Elem()))
+		return nil
+	})
+}
+
+// scanJSONLines calls fn with each JSON object of r, the output of a `docker
+// ... --format "{{ json . }}"` command, and its line number as it is read
+// skipping lines that are not JSON objects (see decodeJSONLines). Scanning
+// stops at the first error returned by fn which is returned as is.
+func scanJSONLines(r io.Reader, fn func(n int, line []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var n int
+	for scanner.Scan() {
+		n++
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '{' {
+			log.Warnf("skipping non-json output on line %d: %s", n, truncate(string(line), maxDecodeSample))
+			continue
+		}
+
+		if err := fn(n, line); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading line %d: %w", n+1, err)
+	}
+
+	return nil
+}
+
+// truncate returns s truncated to at most n bytes with an ellipsis if it
+// was truncated
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,58 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDecodeJSONLines tests decoding the JSON output of a command with one
+// object per line, skipping lines that are not JSON and the context reported
+// for a line that cannot be decoded.
+func TestDecodeJSONLines(t *testing.T) {
+	assert := assert.New(t)
+
+	var nodes Nodes
+	assert.Nil(decodeJSONLines(bytes.NewBufferString("{\"ID\": \"1\"}\n\n{\"ID\": \"2\"}\n"), &nodes))
+	assert.Equal(Nodes{{ID: "1"}, {ID: "2"}}, nodes)
+
+	nodes = nil
+	assert.Nil(decodeJSONLines(bytes.NewBufferString("WARNING: something is deprecated\n{\"ID\": \"1\"}\n"), &nodes))
+	assert.Equal(Nodes{{ID: "1"}}, nodes)
+
+	nodes = nil
+	err := decodeJSONLines(bytes.NewBufferString("{\"ID\": \"1\"}\n{\"ID\": 2}\n"), &nodes)
+	var decodeErr *DecodeError
+	assert.True(errors.As(err, &decodeErr))
+	assert.Equal(2, decodeErr.Line)
+	assert.Equal(`{"ID": 2}`, decodeErr.Sample)
+
+	err = decodeJSONLines(bytes.NewBufferString("{"+strings.Repeat("x", 300)), &nodes)
+	assert.True(errors.As(err, &decodeErr))
+	assert.Len(decodeErr.Sample, maxDecodeSample+len("..."))
+
+	assert.Error(decodeJSONLines(bytes.NewBufferString(""), nodes))
+}
@@ -0,0 +1,145 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// NodeDiagnosis is the state of a node as recorded by the managers, such as
+// the reason a node is Down (e.g: "heartbeat failure for node in \"unknown\"
+// state").
+type NodeDiagnosis struct {
+	ID       string
+	Hostname string
+
+	// State is the node's state (e.g: ready or down) and Message the reason
+	// the managers recorded for it
+	State   string
+	Message string
+
+	// Addr is the address the managers last saw the node at
+	Addr string
+
+	// Reachability is the reachability of the node if it is a manager
+	Reachability string
+}
+
+// Down returns true if the node is Down
+func (d NodeDiagnosis) Down() bool {
+	return strings.EqualFold(d.State, "down")
+}
+
+func (d NodeDiagnosis) String() string {
+	s := fmt.Sprintf("%s (%s) is %s", d.Hostname, d.ID, d.State)
+	if d.Message != "" {
+		s += ": " + d.Message
+	}
+	if d.Addr != "" {
+		s += fmt.Sprintf(" (last seen at %s)", d.Addr)
+	}
+	return s
+}
+
+// DiagnoseNode returns the state of the node with the given hostname and the
+// reason the managers recorded for it, such as why a node is Down.
+func (m *Manager) DiagnoseNode(hostname string) (NodeDiagnosis, error) {
+	node, ok, err := m.GetNode(hostname)
+	if err != nil {
+		return NodeDiagnosis{}, fmt.Errorf("error getting node %s: %w", hostname, err)
+	}
+	if !ok {
+		return NodeDiagnosis{}, fmt.Errorf("error node %s not found", hostname)
+	}
+
+	details, err := m.inspectNodes(node.ID)
+	if err != nil {
+		return NodeDiagnosis{}, fmt.Errorf("error inspecting node %s: %w", hostname, err)
+	}
+	if len(details) != 1 {
+		return NodeDiagnosis{}, fmt.Errorf("error inspecting node %s: expected 1 node but got %d", hostname, len(details))
+	}
+	detail := details[0]
+
+	return NodeDiagnosis{
+		ID:           detail.ID,
+		Hostname:     hostname,
+		State:        detail.Status.State,
+		Message:      detail.Status.Message,
+		Addr:         detail.Status.Addr,
+		Reachability: detail.ManagerStatus.Reachability,
+	}, nil
+}
+
+// DefaultDiagnosticCommands returns the read-only commands RunDiagnostic may
+// run by default
+func DefaultDiagnosticCommands() []string {
+	return []string{
+		"docker info",
+		"docker version",
+		"docker node ls",
+		"docker node ps",
+		"docker ps",
+		"df -h",
+		"free -m",
+		"uptime",
+	}
+}
+
+// RunDiagnostic switches to the node at nodeAddr (e.g: its hostname or
+// public address), runs command and returns its combined output then
+// switches back to the node that was current before. Only commands that
+// exactly match one of the DiagnosticCommands (see WithDiagnosticCommands)
+// may be run so arbitrary commands cannot be run on a node. The output is
+// also returned with a *CommandError if the command fails.
+func (m *Manager) RunDiagnostic(nodeAddr, command string) (string, error) {
+	command = strings.TrimSpace(command)
+	if !HasString(m.config.DiagnosticCommands, command) {
+		return "", fmt.Errorf("error running %q on %s: %w", command, nodeAddr, ErrCommandNotAllowed)
+	}
+
+	var output string
+	err := m.withNode(nodeAddr, func() error {
+		res, err := m.execCmd(m.config.FailureLogLevel, command)
+
+		var cmdErr *CommandError
+		switch {
+		case errors.As(err, &cmdErr):
+			output = cmdErr.Stdout + cmdErr.Stderr
+			return err
+		case err != nil:
+			return err
+		}
+
+		output = res.Stdout.String()
+		if len(res.Warnings) > 0 {
+			output += strings.Join(res.Warnings, "\n") + "\n"
+		}
+		return nil
+	})
+	if err != nil {
+		return output, fmt.Errorf("error running %q on %s: %w", command, nodeAddr, err)
+	}
+
+	return output, nil
+}
@@ -0,0 +1,90 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestDiagnoseNode tests that the reason a node is Down is reported.
+func TestDiagnoseNode(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: testNodes + `{"ID": "3", "Hostname": "dw2", "Status": "Down", "ManagerStatus": ""}
+`})
+	runner.On(`^docker node inspect --format "{{ json . }}" 3$`, swarmtest.Response{
+		Stdout: `{"ID": "3", "Description": {"Hostname": "dw2"}, "Status": {"State": "down", "Message": "heartbeat failure", "Addr": "10.0.0.3"}}`,
+	})
+
+	diagnosis, err := m.DiagnoseNode("dw2")
+	assert.Nil(err)
+	assert.True(diagnosis.Down())
+	assert.Equal("heartbeat failure", diagnosis.Message)
+	assert.Equal("10.0.0.3", diagnosis.Addr)
+	assert.Equal("dw2 (3) is down: heartbeat failure (last seen at 10.0.0.3)", diagnosis.String())
+
+	_, err = m.DiagnoseNode("dw3")
+	assert.Error(err)
+}
+
+// TestRunDiagnostic tests that only allowed commands are run on a node and
+// their output is returned.
+func TestRunDiagnostic(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.OnNode("10.0.0.2", `^df -h$`, swarmtest.Response{Stdout: "Filesystem Size\n", Stderr: "df: /mnt: Stale file handle\n"})
+	runner.OnNode("10.0.0.2", `^docker ps$`, swarmtest.Response{Stderr: "Cannot connect to the Docker daemon\n", ExitCode: 1})
+
+	output, err := m.RunDiagnostic("10.0.0.2", "df -h")
+	assert.Nil(err)
+	assert.Equal("Filesystem Size\ndf: /mnt: Stale file handle\n", output)
+
+	output, err = m.RunDiagnostic("10.0.0.2", "docker ps")
+	var cmdErr *swarm.CommandError
+	assert.ErrorAs(err, &cmdErr)
+	assert.Equal("Cannot connect to the Docker daemon\n", output)
+
+	_, err = m.RunDiagnostic("10.0.0.2", "rm -rf /")
+	assert.ErrorIs(err, swarm.ErrCommandNotAllowed)
+	_, err = m.RunDiagnostic("10.0.0.2", "df -h; rm -rf /")
+	assert.ErrorIs(err, swarm.ErrCommandNotAllowed)
+	assert.Empty(runner.Commands(`^rm`))
+	assert.Empty(runner.Commands(`^df -h;`))
+
+	// The Manager is switched back to the manager
+	assert.Equal([]string{"10.0.0.1", "10.0.0.2", "10.0.0.1", "10.0.0.2", "10.0.0.1"}, m.Switcher().(*swarmtest.FakeSwitcher).Switches())
+
+	m, runner = newTestManager(t, swarm.WithDiagnosticCommands("journalctl -u docker -n 100"))
+	runner.On(`^journalctl`, swarmtest.Response{Stdout: "-- Logs begin --\n"})
+
+	_, err = m.RunDiagnostic("10.0.0.2", "df -h")
+	assert.ErrorIs(err, swarm.ErrCommandNotAllowed)
+	output, err = m.RunDiagnostic("10.0.0.2", "journalctl -u docker -n 100")
+	assert.Nil(err)
+	assert.Equal("-- Logs begin --\n", output)
+}
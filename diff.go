@@ -0,0 +1,185 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RoleMismatch describes a node whose Swarm role differs from the role it
+// is tagged with in the Clusterfile.
+type RoleMismatch struct {
+	Hostname string
+	Desired  string
+	Actual   string
+}
+
+// LabelMismatch describes a node whose Swarm labels differ from the labels
+// it is tagged with in the Clusterfile. Missing holds labels that need to be
+// added (or changed) and Extra holds the keys of labels on the node that are
+// not in the Clusterfile.
+type LabelMismatch struct {
+	Hostname string
+	Missing  map[string]string
+	Extra    []string
+}
+
+// ClusterDiff describes the differences between a set of desired VMNodes
+// (typically from a Clusterfile) and the nodes of a live Swarm cluster.
+type ClusterDiff struct {
+	// Add are the nodes in the Clusterfile that are not part of the cluster
+	Add VMNodes
+
+	// Remove are the hostnames of nodes in the cluster that are not in the
+	// Clusterfile
+	Remove []string
+
+	Roles  []RoleMismatch
+	Labels []LabelMismatch
+}
+
+// Empty returns true if there are no differences
+func (d ClusterDiff) Empty() bool {
+	return len(d.Add) == 0 && len(d.Remove) == 0 && len(d.Roles) == 0 && len(d.Labels) == 0
+}
+
+// desiredLabels returns the Swarm node labels for a VMNode from its LabelsTag
+// in the same `key=value1,value2` form that is applied to the node.
+func desiredLabels(vm VMNode) (map[string]string, error) {
+	labels, err := vm.Labels()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing labels for %s: %w", vm.Hostname, err)
+	}
+
+	res := make(map[string]string)
+	for key, values := range labels {
+		res[key] = LabelValue(values)
+	}
+
+	return res, nil
+}
+
+// nodesByHostname indexes nodes by their hostname preferring a ready node if
+// a hostname appears more than once (e.g: a replaced node that is still Down
+// in the cluster).
+func nodesByHostname(nodes []NodeDetail) map[string]NodeDetail {
+	res := make(map[string]NodeDetail)
+	for _, node := range nodes {
+		if existing, ok := res[node.Hostname()]; ok && existing.Status.State == "ready" {
+			continue
+		}
+		res[node.Hostname()] = node
+	}
+	return res
+}
+
+// diffNodes computes the differences between the desired VMNodes and the
+// detailed information of the nodes currently in the cluster.
+func diffNodes(vms VMNodes, nodes []NodeDetail) (ClusterDiff, error) {
+	var diff ClusterDiff
+
+	current := nodesByHostname(nodes)
+
+	desired := make(map[string]bool)
+
+	for _, vm := range vms {
+		desired[vm.Hostname] = true
+
+		node, ok := current[vm.Hostname]
+		if !ok {
+			diff.Add = append(diff.Add, vm)
+			continue
+		}
+
+		role := vm.GetTag(RoleTag)
+		if (role == ManagerRole || role == WorkerRole) && role != node.Spec.Role {
+			diff.Roles = append(diff.Roles, RoleMismatch{
+				Hostname: vm.Hostname,
+				Desired:  role,
+				Actual:   node.Spec.Role,
+			})
+		}
+
+		labels, err := desiredLabels(vm)
+		if err != nil {
+			return ClusterDiff{}, err
+		}
+
+		mismatch := LabelMismatch{Hostname: vm.Hostname, Missing: make(map[string]string)}
+		for key, value := range labels {
+			if actual, ok := node.Spec.Labels[key]; !ok || actual != value {
+				mismatch.Missing[key] = value
+			}
+		}
+		for key := range node.Spec.Labels {
+			if _, ok := labels[key]; !ok {
+				mismatch.Extra = append(mismatch.Extra, key)
+			}
+		}
+		if len(mismatch.Missing) > 0 || len(mismatch.Extra) > 0 {
+			sort.Strings(mismatch.Extra)
+			diff.Labels = append(diff.Labels, mismatch)
+		}
+	}
+
+	for hostname := range current {
+		if !desired[hostname] {
+			diff.Remove = append(diff.Remove, hostname)
+		}
+	}
+	sort.Strings(diff.Remove)
+
+	return diff, nil
+}
+
+// currentNodes returns the detailed information of all nodes in the cluster
+func (m *Manager) currentNodes() ([]NodeDetail, error) {
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return nil, fmt.Errorf("error getting current nodes: %w", err)
+	}
+
+	var ids []string
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+
+	details, err := m.inspectNodes(ids...)
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting nodes: %w", err)
+	}
+
+	return details, nil
+}
+
+// Diff compares the given VMNodes against the live cluster and returns the
+// nodes to be added, the nodes present in the cluster but not in the given
+// VMNodes, as well as any role or label mismatches. Diff does not make any
+// changes to the cluster.
+func (m *Manager) Diff(vms VMNodes) (ClusterDiff, error) {
+	details, err := m.currentNodes()
+	if err != nil {
+		return ClusterDiff{}, err
+	}
+
+	return diffNodes(vms, details)
+}
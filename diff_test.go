@@ -0,0 +1,91 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDiffNodes tests that `diffNodes()` reports nodes to add and remove as
+// well as role and label mismatches against the live cluster.
+func TestDiffNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	vms := VMNodes{
+		{Hostname: "dm1", Tags: map[string]string{"role": "manager", "labels": "dc=a"}},
+		{Hostname: "dw1", Tags: map[string]string{"role": "worker", "labels": "dc=b&gpu"}},
+		{Hostname: "dw2", Tags: map[string]string{"role": "worker"}},
+	}
+
+	nodes := []NodeDetail{
+		{
+			ID:          "1",
+			Spec:        NodeSpec{Role: "manager", Labels: map[string]string{"dc": "a"}},
+			Description: NodeDescription{Hostname: "dm1"},
+			Status:      NodeState{State: "ready"},
+		},
+		{
+			ID:          "2",
+			Spec:        NodeSpec{Role: "manager", Labels: map[string]string{"dc": "a", "old": "x"}},
+			Description: NodeDescription{Hostname: "dw1"},
+			Status:      NodeState{State: "ready"},
+		},
+		{
+			ID:          "3",
+			Spec:        NodeSpec{Role: "worker"},
+			Description: NodeDescription{Hostname: "dw3"},
+			Status:      NodeState{State: "ready"},
+		},
+	}
+
+	diff, err := diffNodes(vms, nodes)
+	assert.Nil(err)
+	assert.False(diff.Empty())
+
+	assert.Len(diff.Add, 1)
+	assert.Equal("dw2", diff.Add[0].Hostname)
+	assert.Equal([]string{"dw3"}, diff.Remove)
+	assert.Equal([]RoleMismatch{{Hostname: "dw1", Desired: "worker", Actual: "manager"}}, diff.Roles)
+	assert.Equal([]LabelMismatch{{
+		Hostname: "dw1",
+		Missing:  map[string]string{"dc": "b", "gpu": ""},
+		Extra:    []string{"old"},
+	}}, diff.Labels)
+}
+
+// TestDiffNodesEmpty tests that `diffNodes()` reports no differences when the
+// cluster matches the desired nodes.
+func TestDiffNodesEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	vms := VMNodes{{Hostname: "dm1", Tags: map[string]string{"role": "manager"}}}
+	nodes := []NodeDetail{{
+		ID:          "1",
+		Spec:        NodeSpec{Role: "manager"},
+		Description: NodeDescription{Hostname: "dm1"},
+	}}
+
+	diff, err := diffNodes(vms, nodes)
+	assert.Nil(err)
+	assert.True(diff.Empty())
+}
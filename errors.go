@@ -0,0 +1,342 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrAmbiguousNode is returned when a hostname matches more than one node
+	// in the cluster and the match cannot be resolved to a single node.
+	ErrAmbiguousNode = errors.New("hostname matches more than one node")
+
+	// ErrNotCloneable is returned by Manager.Clone when the Manager's
+	// Switcher does not implement Cloner.
+	ErrNotCloneable = errors.New("switcher cannot be cloned")
+
+	// ErrNoRunner is returned when the Manager has no Switcher or its
+	// Switcher has no Runner to run commands with (e.g: it has not been
+	// switched to a node).
+	ErrNoRunner = errors.New("no runner configured")
+
+	// ErrStop is returned by the callback of StreamNodes to stop streaming
+	// nodes early without StreamNodes returning an error.
+	ErrStop = errors.New("stop streaming")
+
+	// ErrInvalidManagerCount matches any *InvalidManagerCountError with
+	// errors.Is.
+	ErrInvalidManagerCount = errors.New("invalid number of managers")
+
+	// ErrCommandNotAllowed is returned by RunDiagnostic for commands that are
+	// not one of the configured DiagnosticCommands.
+	ErrCommandNotAllowed = errors.New("command not allowed")
+)
+
+// UnreachableError is returned when one or more nodes cannot reach the swarm
+// port of the manager they are to join.
+type UnreachableError struct {
+	Addr  string
+	Nodes []string
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf(
+		"nodes %s cannot reach manager %s on port 2377",
+		strings.Join(e.Nodes, ","), e.Addr,
+	)
+}
+
+// HostnameMismatchError is returned when the hostname of a node in the
+// Clusterfile does not match the hostname reported by the node's Docker
+// daemon. Swarm registers nodes under the daemon's hostname so nodes are
+// looked up by it.
+type HostnameMismatchError struct {
+	Addr     string
+	Expected string
+	Actual   string
+}
+
+func (e *HostnameMismatchError) Error() string {
+	return fmt.Sprintf(
+		"node %s has hostname %q but is %q in the Clusterfile",
+		e.Addr, e.Actual, e.Expected,
+	)
+}
+
+// QuorumError is returned when fewer than a majority of the managers of a
+// cluster are reachable and an operation that could wedge the cluster is
+// refused.
+type QuorumError struct {
+	Reachable int
+	Total     int
+}
+
+func (e *QuorumError) Error() string {
+	return fmt.Sprintf(
+		"only %d of %d managers are reachable but quorum requires %d",
+		e.Reachable, e.Total, e.Total/2+1,
+	)
+}
+
+// validManagerCounts are the number of managers a cluster is expected to
+// have so a quorum survives the loss of one (3) or two (5) managers.
+var validManagerCounts = []int{3, 5}
+
+// InvalidManagerCountError is returned when a Clusterfile (or the nodes
+// given to CreateSwarm or UpdateSwarm) has a number of managers other than
+// one of Expected (3 or 5).
+type InvalidManagerCountError struct {
+	Got      int
+	Expected []int
+}
+
+func (e *InvalidManagerCountError) Error() string {
+	var expected []string
+	for _, n := range e.Expected {
+		expected = append(expected, fmt.Sprint(n))
+	}
+	return fmt.Sprintf("expected %s managers but got %d", strings.Join(expected, " or "), e.Got)
+}
+
+func (e *InvalidManagerCountError) Is(target error) bool {
+	return target == ErrInvalidManagerCount
+}
+
+// checkValidManagerCount returns an *InvalidManagerCountError if n is not
+// one of validManagerCounts
+func checkValidManagerCount(n int) error {
+	for _, expected := range validManagerCounts {
+		if n == expected {
+			return nil
+		}
+	}
+	return &InvalidManagerCountError{Got: n, Expected: validManagerCounts}
+}
+
+// GlobalServiceError is returned when a node cannot be drained because the
+// only tasks left on it belong to global services.
+type GlobalServiceError struct {
+	Node     string
+	Services []string
+}
+
+func (e *GlobalServiceError) Error() string {
+	return fmt.Sprintf(
+		"node %s hosts global services %s which will not drain",
+		e.Node, strings.Join(e.Services, ","),
+	)
+}
+
+// ConnectionError is returned when a node cannot be connected to or its
+// Docker daemon is not responding.
+type ConnectionError struct {
+	Node string
+	Err  error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("error connecting to node %s: %s", e.Node, e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// CommandError is returned when a command run on a node exits with a
+// non-zero status. Code is the exit status (e.g: 125 if the Docker daemon
+// failed or 127 if the command was not found) or -1 if it is not known.
+// Stdout is empty for commands whose output is streamed.
+type CommandError struct {
+	Cmd    string
+	Code   int
+	Stdout string
+	Stderr string
+	Err    error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("error running worker: %s (stderr=%q stdout=%q)", e.Err, e.Stderr, e.Stdout)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// exitCode returns the exit status of a command from the error returned by
+// waiting for it (e.g: an *exec.ExitError or *ssh.ExitError) or -1 if err
+// does not carry an exit status.
+func exitCode(err error) int {
+	var status interface{ ExitStatus() int }
+	if errors.As(err, &status) {
+		return status.ExitStatus()
+	}
+
+	var code interface{ ExitCode() int }
+	if errors.As(err, &code) {
+		return code.ExitCode()
+	}
+
+	return -1
+}
+
+// ShortfallError is returned by Scale when there are not enough candidate
+// workers to scale up to the desired number of workers.
+type ShortfallError struct {
+	Desired   int
+	Current   int
+	Available int
+}
+
+func (e *ShortfallError) Error() string {
+	return fmt.Sprintf(
+		"cannot scale to %d workers from %d with %d candidates (short by %d)",
+		e.Desired, e.Current, e.Available, e.Desired-e.Current-e.Available,
+	)
+}
+
+// CapacityError is returned when nodes are not drained because the
+// remaining nodes do not have enough free resources for the resources
+// reserved by the tasks on the nodes.
+type CapacityError struct {
+	Required  Resources
+	Available Resources
+}
+
+func (e *CapacityError) Error() string {
+	return fmt.Sprintf(
+		"remaining nodes have %.2f CPUs and %d MiB free but %.2f CPUs and %d MiB are required",
+		float64(e.Available.NanoCPUs)/1e9, e.Available.MemoryBytes/(1<<20),
+		float64(e.Required.NanoCPUs)/1e9, e.Required.MemoryBytes/(1<<20),
+	)
+}
+
+// CARotationPendingError is returned by RotateCA when every reachable node
+// has a certificate issued by the new root CA but the rotation cannot
+// complete until the nodes that are Down are reachable again.
+type CARotationPendingError struct {
+	Nodes []string
+}
+
+func (e *CARotationPendingError) Error() string {
+	return fmt.Sprintf("CA rotation will complete once %s are reachable", strings.Join(e.Nodes, ","))
+}
+
+// DecodeError is returned when a line of the JSON output of a command
+// cannot be decoded (e.g: a warning printed by the Docker CLI) and includes
+// the line number and a sample of the line.
+type DecodeError struct {
+	Line   int
+	Sample string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("error decoding line %d %q: %s", e.Line, e.Sample, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is a collection of errors from an operation attempted against
+// more than one node.
+type MultiError []error
+
+func (e MultiError) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the errors matches target
+func (e MultiError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target
+func (e MultiError) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveError is returned by RemoveNodes when one or more nodes could not be
+// removed. States holds the state each node was left in keyed by hostname.
+type RemoveError struct {
+	States map[string]RemoveState
+	Err    error
+}
+
+func (e *RemoveError) Error() string {
+	var hostnames []string
+	for hostname := range e.States {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	var states []string
+	for _, hostname := range hostnames {
+		states = append(states, fmt.Sprintf("%s=%s", hostname, e.States[hostname]))
+	}
+
+	return fmt.Sprintf("error removing nodes (%s): %s", strings.Join(states, " "), e.Err)
+}
+
+func (e *RemoveError) Unwrap() error {
+	return e.Err
+}
+
+// WorkerJoinError is returned by CreateSwarm and UpdateSwarm with
+// ContinueOnWorkerError when one or more workers failed to join. The rest of
+// the cluster was completed. Errors holds the error of each worker keyed by
+// hostname.
+type WorkerJoinError struct {
+	Errors map[string]error
+}
+
+func (e *WorkerJoinError) Error() string {
+	var hostnames []string
+	for hostname := range e.Errors {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	var msgs []string
+	for _, hostname := range hostnames {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", hostname, e.Errors[hostname]))
+	}
+
+	return fmt.Sprintf("error %d workers failed to join: %s", len(hostnames), strings.Join(msgs, "; "))
+}
@@ -0,0 +1,52 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestCommandError tests that a failed command is reported as a
+// *swarm.CommandError with its exit code and output.
+func TestCommandError(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node update`, swarmtest.Response{Stderr: "Error response from daemon: node dw9 not found", ExitCode: 1})
+	runner.On(`^docker node ls`, swarmtest.Response{Stderr: "Cannot connect to the Docker daemon", ExitCode: 125})
+
+	var cmdErr *swarm.CommandError
+	if assert.ErrorAs(m.SetAvailability("dw9", swarm.AvailabilityPause), &cmdErr) {
+		assert.Equal(1, cmdErr.Code)
+		assert.Equal("docker node update --availability pause dw9", cmdErr.Cmd)
+		assert.Contains(cmdErr.Stderr, "not found")
+	}
+
+	_, err := m.GetNodes()
+	if assert.ErrorAs(err, &cmdErr) {
+		assert.Equal(125, cmdErr.Code)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"testing"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+const (
+	testManagerInfo = `{"Name": "dm1", "ServerVersion": "20.10.12", "Swarm": {"NodeID": "1", "NodeAddr": "172.16.0.1", "LocalNodeState": "active", "ControlAvailable": true, "RemoteManagers": [{"NodeID": "1", "Addr": "172.16.0.1:2377"}], "Cluster": {"ID": "c1"}}}`
+	testManagerSelf = `{"Leader": true, "Reachability": "reachable", "Addr": "172.16.0.1:2377"}`
+	testNodes       = `{"ID": "1", "Hostname": "dm1", "Status": "Ready", "Availability": "Active", "ManagerStatus": "Leader"}
+{"ID": "2", "Hostname": "dw1", "Status": "Ready", "Availability": "Active", "ManagerStatus": ""}
+`
+)
+
+// newTestManager returns a Manager switched to a fake manager node dm1
+// (10.0.0.1) of an existing cluster along with the FakeRunner used.
+func newTestManager(t *testing.T, options ...swarm.Option) (*swarm.Manager, *swarmtest.FakeRunner) {
+	runner := swarmtest.NewFakeRunner()
+	runner.On(`^docker info`, swarmtest.Response{Stdout: testManagerInfo})
+	runner.On(`^docker node inspect .* self$`, swarmtest.Response{Stdout: testManagerSelf})
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: testNodes})
+
+	m, err := swarm.NewManager(swarmtest.NewFakeSwitcher(runner), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SwitchNode("10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	return m, runner
+}
@@ -0,0 +1,78 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"fmt"
+)
+
+// PostJoinHook is a step run after nodes join a swarm (e.g: applying their
+// labels). Hooks are run in order on a manager once all the nodes have
+// joined and are configured per node by the node's tags in the Clusterfile.
+type PostJoinHook struct {
+	// Name identifies the hook in errors
+	Name string
+
+	// Run runs the hook for the nodes that joined
+	Run func(m *Manager, vms VMNodes) error
+}
+
+// LabelsHook labels each node with its LabelsTag (see LabelNodes). It is
+// skipped if SkipLabeling is set.
+var LabelsHook = PostJoinHook{
+	Name: "labels",
+	Run: func(m *Manager, vms VMNodes) error {
+		if m.config.SkipLabeling {
+			return nil
+		}
+		if err := m.LabelNodes(vms); err != nil {
+			return err
+		}
+		m.progress(PhaseLabeled, "", len(vms), len(vms))
+		return nil
+	},
+}
+
+// AvailabilityHook sets the availability of each node with an
+// AvailabilityTag other than "active" (the availability nodes join with).
+var AvailabilityHook = PostJoinHook{
+	Name: "availability",
+	Run: func(m *Manager, vms VMNodes) error {
+		return m.applyAvailability(vms)
+	},
+}
+
+// DefaultPostJoinHooks returns the hooks run after nodes join a swarm by
+// default: LabelsHook and then AvailabilityHook.
+func DefaultPostJoinHooks() []PostJoinHook {
+	return []PostJoinHook{LabelsHook, AvailabilityHook}
+}
+
+// postJoin runs the configured PostJoinHooks in order for the nodes that
+// joined and stops at the first that fails.
+func (m *Manager) postJoin(vms VMNodes) error {
+	for _, hook := range m.config.PostJoinHooks {
+		if err := hook.Run(m, vms); err != nil {
+			return fmt.Errorf("error running %s post-join hook: %w", hook.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestCreateSwarmPostJoinHooks tests that custom post-join hooks are run
+// after the default hooks with the nodes that joined.
+func TestCreateSwarmPostJoinHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	var hooked []string
+	hook := swarm.PostJoinHook{
+		Name: "test",
+		Run: func(m *swarm.Manager, vms swarm.VMNodes) error {
+			for _, vm := range vms {
+				hooked = append(hooked, vm.Hostname)
+			}
+			return nil
+		},
+	}
+
+	m, runner := newTestManager(t, swarm.WithPostJoinHooks(hook))
+	runner.OnNode(
+		"10.0.0.1", `^docker info`,
+		swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`},
+		swarmtest.Response{Stdout: testManagerInfo},
+	)
+	runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw1"}`})
+	runner.On(`^docker swarm init`)
+	runner.On(`^docker swarm join`)
+	runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+	runner.On(`^docker node update`)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager", "labels": "zone=a"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+	}
+
+	assert.Nil(m.CreateSwarm(vms, true))
+	assert.Equal([]string{"dm1", "dw1"}, hooked)
+	assert.Equal([]string{`docker node update --label-add 'zone=a' 1`}, runner.Commands(`^docker node update`))
+}
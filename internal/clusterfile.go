@@ -0,0 +1,98 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aucloud/go-swarm"
+)
+
+// readClusterfile reads the Clusterfile at path or from standard input if
+// path is "-". Reading from standard input times out after StdinTimeout so
+// a forgotten pipe fails rather than hanging.
+func readClusterfile(path string) (swarm.Clusterfile, error) {
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return swarm.Clusterfile{}, err
+		}
+		defer f.Close()
+
+		return swarm.ReadClusterfile(f)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), StdinTimeout)
+	defer cancel()
+
+	cf, err := swarm.ReadClusterfileContext(ctx, os.Stdin)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return swarm.Clusterfile{}, fmt.Errorf(
+			"timed out after %s waiting for Clusterfile on standard input (did you forget to pipe it?)",
+			StdinTimeout,
+		)
+	}
+
+	return cf, err
+}
+
+// readClusterfiles reads and merges the Clusterfiles at paths in order (see
+// swarm.MergeClusterfiles). Standard input ("-") may only be read once.
+func readClusterfiles(paths []string) (swarm.Clusterfile, error) {
+	var (
+		clusterFiles []swarm.Clusterfile
+		stdin        bool
+	)
+
+	for _, path := range paths {
+		if path == "-" {
+			if stdin {
+				return swarm.Clusterfile{}, fmt.Errorf("standard input can only be read once")
+			}
+			stdin = true
+		}
+
+		cf, err := readClusterfile(path)
+		if err != nil {
+			return swarm.Clusterfile{}, fmt.Errorf("%s: %w", path, err)
+		}
+		clusterFiles = append(clusterFiles, cf)
+	}
+
+	return swarm.MergeClusterfiles(clusterFiles...)
+}
+
+// printValidateError prints an error validating a Clusterfile to standard
+// error along with a hint for the most common mistakes.
+func printValidateError(err error) {
+	fmt.Fprintf(os.Stderr, "error validating Clusterfile: %s\n", err)
+
+	var countErr *swarm.InvalidManagerCountError
+	if errors.As(err, &countErr) {
+		fmt.Fprintf(
+			os.Stderr, "hint: tag 3 or 5 nodes with `%s: %s` so a quorum of managers survives losing a manager\n",
+			swarm.RoleTag, swarm.ManagerRole,
+		)
+	}
+}
@@ -20,6 +20,8 @@
 
 package internal
 
+import "time"
+
 const (
 	// DefaultSSHUser is the default SSH Username when executing remote commands
 	DefaultSSHUser = "rancher"
@@ -30,6 +32,9 @@ const (
 	// DefaultSockPath is the default path to the Docker API's UNIX Socket
 	DefaultSockPath = "/var/run/docker.sock"
 
+	// StdinTimeout is how long to wait for a Clusterfile on standard input
+	StdinTimeout = time.Second * 30
+
 	// MinSwarmClusterNodes is the minimum number of  nodes to form a swam cluster
 	MinSwarmClusterNodes = 1
 )
@@ -28,13 +28,78 @@ import (
 	"github.com/aucloud/go-swarm"
 )
 
-func Drain(m *swarm.Manager, args []string) int {
-	if err := m.DrainNodes(args); err != nil {
-		fmt.Fprintf(os.Stderr, "error draining nodes: %s\n", err)
+// Drain drains the nodes with the hostnames given in args followed by the
+// nodes in the Clusterfile (if any) with the given role (or all of its nodes
+// if role is empty) one at a time, printing the progress of each node.
+func Drain(m swarm.Swarmer, args []string, clusterFile, role string) int {
+	hostnames, err := drainHostnames(args, clusterFile, role)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading nodes to drain: %s\n", err)
 		return StatusError
 	}
+	if len(hostnames) == 0 {
+		fmt.Fprintf(os.Stderr, "error no nodes to drain\n")
+		return StatusError
+	}
+
+	for i, hostname := range hostnames {
+		fmt.Fprintf(os.Stdout, "Draining node %s (%d/%d) ...\n", hostname, i+1, len(hostnames))
+
+		result, err := m.DrainNode(hostname)
+		fmt.Fprintf(
+			os.Stdout, "Node %s: %d tasks from services %s rescheduled in %s\n",
+			result.Node, len(result.Tasks), strings.Join(result.Services, ","), result.Elapsed,
+		)
+		if len(result.Stuck) > 0 {
+			fmt.Fprintf(
+				os.Stdout, "Node %s: tasks %s could not be rescheduled\n",
+				result.Node, strings.Join(result.Stuck, ","),
+			)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error draining nodes: %s\n", err)
+			return StatusError
+		}
+	}
 
-	fmt.Fprintf(os.Stdout, "Nodes %s successfully drained\n", strings.Join(args, ","))
+	fmt.Fprintf(os.Stdout, "Nodes %s successfully drained\n", strings.Join(hostnames, ","))
 
 	return Status(m, nil)
 }
+
+// drainHostnames returns the hostnames in args followed by the hostnames of
+// the nodes in clusterFile (if not empty) with the given role (if not empty)
+// without duplicates.
+func drainHostnames(args []string, clusterFile, role string) ([]string, error) {
+	if role != "" && role != swarm.ManagerRole && role != swarm.WorkerRole {
+		return nil, fmt.Errorf("invalid role %q (expected %s or %s)", role, swarm.ManagerRole, swarm.WorkerRole)
+	}
+
+	var hostnames []string
+	for _, hostname := range args {
+		if !swarm.HasString(hostnames, hostname) {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+
+	if clusterFile == "" {
+		return hostnames, nil
+	}
+
+	cf, err := readClusterfile(clusterFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", clusterFile, err)
+	}
+
+	nodes := cf.Nodes
+	if role != "" {
+		nodes = nodes.FilterByTag(swarm.RoleTag, role)
+	}
+	for _, node := range nodes {
+		if !swarm.HasString(hostnames, node.Hostname) {
+			hostnames = append(hostnames, node.Hostname)
+		}
+	}
+
+	return hostnames, nil
+}
@@ -0,0 +1,57 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainHostnames(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "Clusterfile")
+	assert.Nil(ioutil.WriteFile(path, []byte(`{
+  "nodes": [
+    {"hostname": "dm1", "private_address": "172.16.0.1", "tags": {"role": "manager"}},
+    {"hostname": "dw1", "private_address": "172.16.0.2", "tags": {"role": "worker"}},
+    {"hostname": "dw2", "private_address": "172.16.0.3", "tags": {"role": "worker"}}
+  ]
+}`), 0644))
+
+	hostnames, err := drainHostnames([]string{"dw3", "dw3"}, "", "")
+	assert.Nil(err)
+	assert.Equal([]string{"dw3"}, hostnames)
+
+	hostnames, err = drainHostnames([]string{"dw2"}, path, "worker")
+	assert.Nil(err)
+	assert.Equal([]string{"dw2", "dw1"}, hostnames)
+
+	hostnames, err = drainHostnames(nil, path, "")
+	assert.Nil(err)
+	assert.Equal([]string{"dm1", "dw1", "dw2"}, hostnames)
+
+	_, err = drainHostnames(nil, path, "workers")
+	assert.Error(err)
+}
@@ -27,7 +27,7 @@ import (
 	"github.com/aucloud/go-swarm"
 )
 
-func Info(m *swarm.Manager, args []string) int {
+func Info(m swarm.Swarmer, args []string) int {
 	node, err := m.GetInfo()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error getting node info: %s\n", err)
@@ -40,10 +40,10 @@ func Info(m *swarm.Manager, args []string) int {
 		return StatusError
 	}
 
-	fmt.Fprintf(os.Stdout, "Cluster ID: %s\n", node.Swarm.Cluster.ID)
+	fmt.Fprintf(os.Stdout, "Cluster ID: %s\n", node.Swarm.ClusterID())
 	fmt.Fprintf(os.Stdout, "Nodes: %d\n", node.Swarm.Nodes)
 	fmt.Fprintf(os.Stdout, "Managers: %d\n", node.Swarm.Managers)
-	fmt.Fprintf(os.Stdout, "Workers: %d\n", (node.Swarm.Nodes - node.Swarm.Managers))
+	fmt.Fprintf(os.Stdout, "Workers: %d\n", node.Swarm.Workers())
 
 	fmt.Fprintf(os.Stdout, "Managers:\n")
 	for _, manager := range managers {
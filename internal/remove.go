@@ -0,0 +1,56 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aucloud/go-swarm"
+)
+
+func Remove(m swarm.Swarmer, args []string) int {
+	err := m.RemoveNodes(args)
+
+	var removeErr *swarm.RemoveError
+	if errors.As(err, &removeErr) {
+		var hostnames []string
+		for hostname := range removeErr.States {
+			hostnames = append(hostnames, hostname)
+		}
+		sort.Strings(hostnames)
+
+		for _, hostname := range hostnames {
+			fmt.Fprintf(os.Stdout, "Node %s: %s\n", hostname, removeErr.States[hostname])
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error removing nodes: %s\n", err)
+		return StatusError
+	}
+
+	fmt.Fprintf(os.Stdout, "Nodes %s successfully removed\n", strings.Join(args, ","))
+
+	return Status(m, nil)
+}
@@ -22,29 +22,52 @@ package internal
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"text/tabwriter"
 
 	"github.com/aucloud/go-swarm"
 )
 
-func Status(m *swarm.Manager, args []string) int {
+func Status(m swarm.Swarmer, args []string) int {
 	nodes, err := m.GetNodes()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error getting nodes: %s\n", err)
 		return StatusError
 	}
 
+	if err := writeStatus(os.Stdout, nodes); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing status: %s\n", err)
+		return StatusError
+	}
+
+	return StatusOK
+}
+
+// writeStatus writes a table of the nodes with their role, status,
+// availability and (for managers) reachability.
+func writeStatus(w io.Writer, nodes swarm.Nodes) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ID\tHOSTNAME\tROLE\tSTATUS\tAVAILABILITY\tREACHABILITY\tENGINE VERSION")
+
 	for _, node := range nodes {
+		reachability := node.ManagerStatus
+		if reachability == "" {
+			reachability = "-"
+		}
+
 		fmt.Fprintf(
-			os.Stdout, "%s %s %s %s %s %s\n",
+			tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 			node.ID,
 			node.Hostname,
+			node.Role(),
 			node.Status,
 			node.Availability,
-			node.ManagerStatus,
+			reachability,
 			node.EngineVersion,
 		)
 	}
 
-	return StatusOK
+	return tw.Flush()
 }
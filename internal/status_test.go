@@ -0,0 +1,48 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+)
+
+func TestWriteStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	nodes := swarm.Nodes{
+		{ID: "1", Hostname: "dm1", Status: "Ready", Availability: "Active", ManagerStatus: "Leader", EngineVersion: "20.10.12"},
+		{ID: "2", Hostname: "dw1", Status: "Ready", Availability: "Drain", EngineVersion: "20.10.12"},
+	}
+
+	buf := &bytes.Buffer{}
+	assert.Nil(writeStatus(buf, nodes))
+	assert.Equal(
+		"ID  HOSTNAME  ROLE     STATUS  AVAILABILITY  REACHABILITY  ENGINE VERSION\n"+
+			"1   dm1       manager  Ready   Active        Leader        20.10.12\n"+
+			"2   dw1       worker   Ready   Drain         -             20.10.12\n",
+		buf.String(),
+	)
+}
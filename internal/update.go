@@ -22,40 +22,22 @@ package internal
 
 import (
 	"fmt"
-	"io"
 	"os"
 
 	"github.com/aucloud/go-swarm"
 )
 
-func Update(m *swarm.Manager, args []string) int {
-	var (
-		f   io.ReadCloser
-		err error
-	)
-
-	clusterFile := args[0]
-
-	if clusterFile == "-" {
-		f = os.Stdin
-	} else {
-		f, err = os.Open(clusterFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "error reading Clusterfile: %s\n", err)
-			return StatusError
-		}
-	}
-
-	cf, err := swarm.ReadClusterfile(f)
+func Update(m swarm.Swarmer, args []string) int {
+	cf, err := readClusterfiles(args)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error parsing Clusterfile: %s\n", err)
+		fmt.Fprintf(os.Stderr, "error reading Clusterfile: %s\n", err)
 		return StatusError
 	}
 
 	// TODO: Validate no existing cluster exists in this cf.Nodes (VMNodes)
 	// TODO: Modify Validate to take VMNodes as input.
 	if err := cf.Validate(); err != nil {
-		fmt.Fprintf(os.Stderr, "error validating Clusterfile: %s\n", err)
+		printValidateError(err)
 		return StatusError
 	}
 
@@ -70,7 +52,7 @@ func Update(m *swarm.Manager, args []string) int {
 		return StatusError
 	}
 
-	fmt.Fprintf(os.Stdout, "Swarm Cluster successfully updated with id: %s\n", node.Swarm.Cluster.ID)
+	fmt.Fprintf(os.Stdout, "Swarm Cluster successfully updated with id: %s\n", node.Swarm.ClusterID())
 
 	return Status(m, nil)
 }
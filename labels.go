@@ -0,0 +1,462 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// labelOptions returns the `--label-add` options for the labels in the
+// VMNode's LabelsTag.
+func labelOptions(node VMNode) ([]string, error) {
+	labels, err := node.Labels()
+	if err != nil {
+		log.WithError(err).Error("error parsing labels")
+		return nil, fmt.Errorf("error parsing labels: %w", err)
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var options []string
+	for _, key := range keys {
+		options = append(options, fmt.Sprintf(labelAdd, ShellQuote(FormatLabel(key, labels[key]))))
+	}
+
+	return options, nil
+}
+
+// LabelNode applies the labels in the VMNode's LabelsTag to the node in the
+// cluster.
+func (m *Manager) LabelNode(node VMNode) error {
+	return m.LabelNodes(VMNodes{node})
+}
+
+// LabelNodes applies the labels in each VMNode's LabelsTag to the nodes in
+// the cluster. The ID of each node is resolved from a single listing of the
+// cluster's nodes and all updates are issued from a manager so there is no
+// need to connect to each node individually.
+func (m *Manager) LabelNodes(vms VMNodes) error {
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	for _, vm := range vms {
+		if engineLabels := vm.GetTag(EngineLabelsTag); engineLabels != "" {
+			log.Warnf(
+				"ignoring engine labels %q for %s: engine labels must be set in the Docker daemon configuration",
+				engineLabels, vm.Hostname,
+			)
+		}
+
+		options, err := labelOptions(vm)
+		if err != nil {
+			return fmt.Errorf("error getting labels for %s: %w", vm.Hostname, err)
+		}
+
+		if len(options) == 0 {
+			// No labels, nothing to do.
+			continue
+		}
+
+		node, ok, err := nodes.FindByHostname(vm.Hostname)
+		if err != nil {
+			return fmt.Errorf("error finding node %s: %w", vm.Hostname, err)
+		}
+		if !ok {
+			return fmt.Errorf("error node %s not found in cluster", vm.Hostname)
+		}
+
+		if err := m.updateNode(node.ID, options...); err != nil {
+			return fmt.Errorf("error updating %s: %w", vm.Hostname, err)
+		}
+	}
+
+	return nil
+}
+
+// ApplyLabels applies the labels of each of the given VMNodes (from their
+// LabelsTag) to the nodes in the cluster in a single pass from a manager.
+// Nodes that already have all of their labels are skipped and labels on the
+// nodes that are not in the Clusterfile are left alone. The LabelSyncResult
+// returned has a LabelChange for each node whose labels were applied.
+func (m *Manager) ApplyLabels(vms VMNodes) (LabelSyncResult, error) {
+	details, err := m.currentNodes()
+	if err != nil {
+		return LabelSyncResult{}, err
+	}
+
+	diff, err := diffNodes(vms, details)
+	if err != nil {
+		return LabelSyncResult{}, fmt.Errorf("error computing label differences: %w", err)
+	}
+
+	current := nodesByHostname(details)
+
+	var result LabelSyncResult
+	for _, mismatch := range diff.Labels {
+		if len(mismatch.Missing) == 0 {
+			continue
+		}
+
+		keys := make([]string, 0, len(mismatch.Missing))
+		for key := range mismatch.Missing {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var labelOptions []string
+		for _, key := range keys {
+			label := FormatLabel(key, []string{mismatch.Missing[key]})
+			labelOptions = append(labelOptions, fmt.Sprintf(labelAdd, ShellQuote(label)))
+		}
+
+		id := current[mismatch.Hostname].ID
+		if err := m.updateNode(id, labelOptions...); err != nil {
+			return result, fmt.Errorf("error updating %s: %w", mismatch.Hostname, err)
+		}
+
+		log.Infof("Applied labels %s to %s", strings.Join(keys, ","), mismatch.Hostname)
+		result.Changes = append(result.Changes, LabelChange{
+			Hostname: mismatch.Hostname,
+			ID:       id,
+			Added:    mismatch.Missing,
+		})
+	}
+
+	return result, nil
+}
+
+// LabelChange is the change made to the labels of a node by SyncLabels or
+// ApplyLabels.
+// Added holds the labels added (or changed) and Removed the keys of the
+// labels removed.
+type LabelChange struct {
+	Hostname string
+	ID       string
+	Added    map[string]string
+	Removed  []string
+}
+
+// LabelSyncResult is the result of SyncLabels or ApplyLabels with a
+// LabelChange for each node whose labels were changed.
+type LabelSyncResult struct {
+	Changes []LabelChange
+}
+
+// Changed returns the hostnames of the nodes whose labels were changed
+func (r LabelSyncResult) Changed() []string {
+	var hostnames []string
+	for _, change := range r.Changes {
+		hostnames = append(hostnames, change.Hostname)
+	}
+	return hostnames
+}
+
+// SyncLabels makes the labels of the nodes in the cluster match the labels
+// of each of the given VMNodes (from their LabelsTag). The current labels of
+// every node are read with a single inspect from a manager and only the
+// differences are applied: missing or changed labels are added and labels
+// that are not in the Clusterfile are removed. Nodes whose labels already
+// match are not updated so SyncLabels can be safely re-run. No changes are
+// made if any of the VMNodes are not in the cluster.
+func (m *Manager) SyncLabels(vms VMNodes) (LabelSyncResult, error) {
+	if err := m.ensureManager(); err != nil {
+		return LabelSyncResult{}, fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	details, err := m.currentNodes()
+	if err != nil {
+		return LabelSyncResult{}, err
+	}
+
+	diff, err := diffNodes(vms, details)
+	if err != nil {
+		return LabelSyncResult{}, fmt.Errorf("error computing label differences: %w", err)
+	}
+	if len(diff.Add) > 0 {
+		return LabelSyncResult{}, fmt.Errorf("error node %s not found in cluster", diff.Add[0].Hostname)
+	}
+
+	current := nodesByHostname(details)
+
+	var result LabelSyncResult
+	for _, mismatch := range diff.Labels {
+		change := LabelChange{
+			Hostname: mismatch.Hostname,
+			ID:       current[mismatch.Hostname].ID,
+			Added:    mismatch.Missing,
+			Removed:  mismatch.Extra,
+		}
+
+		options, err := buildNodeUpdateOptions(NodeSpecUpdate{AddLabels: change.Added, RemoveLabels: change.Removed})
+		if err != nil {
+			return result, fmt.Errorf("error updating labels of %s: %w", change.Hostname, err)
+		}
+
+		if err := m.updateNode(change.ID, options...); err != nil {
+			return result, fmt.Errorf("error updating %s: %w", change.Hostname, err)
+		}
+
+		log.Infof("Synced labels of %s", change.Hostname)
+		result.Changes = append(result.Changes, change)
+	}
+
+	return result, nil
+}
+
+// GetNodeLabels returns the labels of the node with the given hostname
+// including the DescriptionLabel if the node has a description.
+func (m *Manager) GetNodeLabels(hostname string) (map[string]string, error) {
+	node, ok, err := m.GetNode(hostname)
+	if err != nil {
+		return nil, fmt.Errorf("error finding node %s: %w", hostname, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("error node %s not found in cluster", hostname)
+	}
+
+	details, err := m.inspectNodes(node.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting node %s: %w", hostname, err)
+	}
+	if len(details) != 1 {
+		return nil, fmt.Errorf("error inspecting node %s: expected 1 node but got %d", hostname, len(details))
+	}
+
+	return details[0].Spec.Labels, nil
+}
+
+// applyAvailability sets the availability of each VMNode with an
+// AvailabilityTag other than "active" (the availability nodes join with).
+func (m *Manager) applyAvailability(vms VMNodes) error {
+	pending := make(map[string]Availability)
+	for _, vm := range vms {
+		if tag := vm.GetTag(AvailabilityTag); tag != "" {
+			availability, err := ParseAvailability(tag)
+			if err != nil {
+				return fmt.Errorf("error node %s has %w", vm.Hostname, err)
+			}
+			if availability != AvailabilityActive {
+				pending[vm.Hostname] = availability
+			}
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	for _, vm := range vms {
+		availability, ok := pending[vm.Hostname]
+		if !ok {
+			continue
+		}
+
+		node, ok, err := nodes.FindByHostname(vm.Hostname)
+		if err != nil {
+			return fmt.Errorf("error finding node %s: %w", vm.Hostname, err)
+		}
+		if !ok {
+			return fmt.Errorf("error node %s not found in cluster", vm.Hostname)
+		}
+
+		if err := m.updateAvailability(node.ID, availability); err != nil {
+			return fmt.Errorf("error setting availability of %s: %w", vm.Hostname, err)
+		}
+	}
+
+	return nil
+}
+
+// updateNode runs `docker node update` with the given options against a node
+// given by any reference Docker resolves (its ID, an unambiguous ID prefix or
+// its hostname) without looking the node up first. This must be run on a
+// manager node.
+func (m *Manager) updateNode(ref string, options ...string) error {
+	cmd := fmt.Sprintf(updateCommand, strings.Join(options, " "), ref)
+	if _, err := m.runMutatingCmd(cmd); err != nil {
+		return fmt.Errorf("error running update command: %w", err)
+	}
+	return nil
+}
+
+// updateAvailability sets the availability of a node given by its ID or
+// hostname. This must be run on a manager node.
+func (m *Manager) updateAvailability(node string, availability Availability) error {
+	return m.updateNode(node, fmt.Sprintf(setAvailability, availability))
+}
+
+// SetAvailability sets the availability of a node given by its ID or
+// hostname. Unlike DrainNodes it does not wait for a drained node's tasks
+// to be rescheduled.
+func (m *Manager) SetAvailability(node string, availability Availability) error {
+	if _, err := ParseAvailability(string(availability)); err != nil {
+		return err
+	}
+
+	if err := m.ensureManager(); err != nil {
+		return fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	if err := m.updateAvailability(node, availability); err != nil {
+		return fmt.Errorf("error setting availability of %s: %w", node, err)
+	}
+
+	return nil
+}
+
+// Cordon marks a node given by its ID or hostname as unschedulable like
+// `kubectl cordon`: tasks already running on it keep running but no new tasks
+// are scheduled on it. This pauses the node. Use DrainNode to also evict its
+// tasks.
+func (m *Manager) Cordon(node string) error {
+	return m.SetAvailability(node, AvailabilityPause)
+}
+
+// Uncordon marks a node given by its ID or hostname as schedulable again like
+// `kubectl uncordon` by making it active. This also undoes DrainNode.
+func (m *Manager) Uncordon(node string) error {
+	return m.SetAvailability(node, AvailabilityActive)
+}
+
+// NodeSpecUpdate is a set of changes to a node applied together by
+// UpdateNode. Fields left as their zero value are not changed.
+type NodeSpecUpdate struct {
+	// Availability is the new availability of the node
+	Availability Availability
+
+	// Role is the new role of the node, ManagerRole or WorkerRole
+	Role string
+
+	// AddLabels are labels to add to the node or change
+	AddLabels map[string]string
+
+	// RemoveLabels are the keys of labels to remove from the node
+	RemoveLabels []string
+}
+
+// buildNodeUpdateOptions returns the `docker node update` options for the
+// changes in spec.
+func buildNodeUpdateOptions(spec NodeSpecUpdate) ([]string, error) {
+	var options []string
+
+	if spec.Availability != "" {
+		if _, err := ParseAvailability(string(spec.Availability)); err != nil {
+			return nil, err
+		}
+		options = append(options, fmt.Sprintf(setAvailability, spec.Availability))
+	}
+
+	if spec.Role != "" {
+		if spec.Role != ManagerRole && spec.Role != WorkerRole {
+			return nil, fmt.Errorf("invalid role %q (expected %s or %s)", spec.Role, ManagerRole, WorkerRole)
+		}
+		options = append(options, fmt.Sprintf(setRole, spec.Role))
+	}
+
+	keys := make([]string, 0, len(spec.AddLabels))
+	for key := range spec.AddLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		label := FormatLabel(key, []string{spec.AddLabels[key]})
+		options = append(options, fmt.Sprintf(labelAdd, ShellQuote(label)))
+	}
+
+	for _, key := range spec.RemoveLabels {
+		if _, ok := spec.AddLabels[key]; ok {
+			return nil, fmt.Errorf("label %q cannot be both added and removed", key)
+		}
+		options = append(options, fmt.Sprintf(labelRm, ShellQuote(key)))
+	}
+
+	return options, nil
+}
+
+// UpdateNode applies all of the changes in spec to the node with the given
+// hostname with a single `docker node update` so they are applied together.
+// The node's ID is resolved first so a hostname shared with a replaced node
+// that is still Down is not ambiguous (see UpdateNodeRef to skip this).
+// Note that changing the role of a manager to a worker demotes it.
+func (m *Manager) UpdateNode(hostname string, spec NodeSpecUpdate) error {
+	options, err := buildNodeUpdateOptions(spec)
+	if err != nil {
+		return fmt.Errorf("error updating node %s: %w", hostname, err)
+	}
+	if len(options) == 0 {
+		// Nothing to update.
+		return nil
+	}
+
+	node, ok, err := m.GetNode(hostname)
+	if err != nil {
+		return fmt.Errorf("error finding node %s: %w", hostname, err)
+	}
+	if !ok {
+		return fmt.Errorf("error node %s not found in cluster", hostname)
+	}
+
+	if err := m.updateNode(node.ID, options...); err != nil {
+		return fmt.Errorf("error updating %s: %w", hostname, err)
+	}
+
+	return nil
+}
+
+// UpdateNodeRef is UpdateNode for a node given by any reference Docker
+// resolves (its ID or hostname) which is passed to `docker node update` as
+// is without listing the cluster's nodes first. Use this when the reference
+// is already known to be unambiguous, such as an ID from GetNodes.
+func (m *Manager) UpdateNodeRef(ref string, spec NodeSpecUpdate) error {
+	options, err := buildNodeUpdateOptions(spec)
+	if err != nil {
+		return fmt.Errorf("error updating node %s: %w", ref, err)
+	}
+	if len(options) == 0 {
+		// Nothing to update.
+		return nil
+	}
+
+	if err := m.ensureManager(); err != nil {
+		return fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	if err := m.updateNode(ref, options...); err != nil {
+		return fmt.Errorf("error updating %s: %w", ref, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestLabelNodes tests that `Manager.LabelNodes()` resolves node IDs from
+// the manager and labels all nodes without switching to them.
+func TestLabelNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node update`)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", Tags: map[string]string{"labels": "zone=a&gpu"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", Tags: map[string]string{"labels": "zone=b", "engine_labels": "disk=ssd"}},
+		{Hostname: "dw2", PublicAddress: "10.0.0.3"},
+	}
+
+	assert.Nil(m.LabelNodes(vms))
+	assert.Equal([]string{
+		`docker node update --label-add 'gpu' --label-add 'zone=a' 1`,
+		`docker node update --label-add 'zone=b' 2`,
+	}, runner.Commands(`^docker node update`))
+	assert.Len(runner.Commands(`^docker node ls`), 1)
+	assert.Equal([]string{"10.0.0.1"}, m.Switcher().(*swarmtest.FakeSwitcher).Switches())
+}
+
+// TestNodeDescription tests that a node's description is applied as the
+// description label and read back with its labels.
+func TestNodeDescription(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node update`)
+	runner.On(`^docker node inspect --format "{{ json . }}" 2$`, swarmtest.Response{
+		Stdout: `{"ID": "2", "Spec": {"Labels": {"zone": "b", "com.aucloud.swarm.description": "GPU node, don't drain"}}, "Description": {"Hostname": "dw1"}}`,
+	})
+
+	vms := swarm.VMNodes{
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", Tags: map[string]string{"labels": "zone=b", "description": "GPU node, don't drain"}},
+	}
+
+	assert.Nil(m.LabelNodes(vms))
+	assert.Equal([]string{
+		`docker node update --label-add 'com.aucloud.swarm.description=GPU node, don'\''t drain' --label-add 'zone=b' 2`,
+	}, runner.Commands(`^docker node update`))
+
+	labels, err := m.GetNodeLabels("dw1")
+	assert.Nil(err)
+	assert.Equal("b", labels["zone"])
+	assert.Equal("GPU node, don't drain", labels[swarm.DescriptionLabel])
+
+	_, err = m.GetNodeLabels("dw9")
+	assert.Error(err)
+}
+
+// TestSyncLabels tests that only the label differences are applied from a
+// single manager.
+func TestSyncLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node inspect --format "{{ json . }}" 1 2$`, swarmtest.Response{Stdout: `{"ID": "1", "Spec": {"Labels": {"zone": "a"}}, "Description": {"Hostname": "dm1"}, "Status": {"State": "ready"}}
+{"ID": "2", "Spec": {"Labels": {"zone": "a", "disk": "hdd"}}, "Description": {"Hostname": "dw1"}, "Status": {"State": "ready"}}
+`})
+	runner.On(`^docker node update`)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", Tags: map[string]string{"labels": "zone=a"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", Tags: map[string]string{"labels": "zone=b&gpu"}},
+	}
+
+	result, err := m.SyncLabels(vms)
+	assert.Nil(err)
+	assert.Equal([]string{"dw1"}, result.Changed())
+	assert.Equal(map[string]string{"zone": "b", "gpu": ""}, result.Changes[0].Added)
+	assert.Equal([]string{"disk"}, result.Changes[0].Removed)
+	assert.Equal([]string{
+		`docker node update --label-add 'gpu' --label-add 'zone=b' --label-rm 'disk' 2`,
+	}, runner.Commands(`^docker node update`))
+	assert.Equal([]string{"10.0.0.1"}, m.Switcher().(*swarmtest.FakeSwitcher).Switches())
+
+	vms = append(vms, swarm.VMNode{Hostname: "dw2", PublicAddress: "10.0.0.3"})
+	_, err = m.SyncLabels(vms)
+	assert.Error(err)
+	assert.Len(runner.Commands(`^docker node update`), 1)
+}
+
+// TestApplyLabels tests that only the nodes missing labels are updated and
+// reported as changed and that labels not in the Clusterfile are left alone.
+func TestApplyLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(
+		`^docker node inspect --format "{{ json . }}" 1 2$`,
+		swarmtest.Response{Stdout: `{"ID": "1", "Spec": {"Labels": {"zone": "a"}}, "Description": {"Hostname": "dm1"}, "Status": {"State": "ready"}}
+{"ID": "2", "Spec": {"Labels": {"zone": "a", "disk": "hdd"}}, "Description": {"Hostname": "dw1"}, "Status": {"State": "ready"}}
+`},
+		swarmtest.Response{Stdout: `{"ID": "1", "Spec": {"Labels": {"zone": "a"}}, "Description": {"Hostname": "dm1"}, "Status": {"State": "ready"}}
+{"ID": "2", "Spec": {"Labels": {"zone": "b", "gpu": "", "disk": "hdd"}}, "Description": {"Hostname": "dw1"}, "Status": {"State": "ready"}}
+`},
+	)
+	runner.On(`^docker node update`)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", Tags: map[string]string{"labels": "zone=a"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", Tags: map[string]string{"labels": "zone=b&gpu"}},
+	}
+
+	result, err := m.ApplyLabels(vms)
+	assert.NoError(err)
+	assert.Equal([]string{"dw1"}, result.Changed())
+	assert.Equal("2", result.Changes[0].ID)
+	assert.Equal(map[string]string{"zone": "b", "gpu": ""}, result.Changes[0].Added)
+	assert.Empty(result.Changes[0].Removed)
+	assert.Equal([]string{
+		`docker node update --label-add 'gpu' --label-add 'zone=b' 2`,
+	}, runner.Commands(`^docker node update`))
+
+	result, err = m.ApplyLabels(vms)
+	assert.NoError(err)
+	assert.Empty(result.Changed())
+	assert.Len(runner.Commands(`^docker node update`), 1)
+}
+
+// TestSetAvailability tests setting the availability of a node.
+func TestSetAvailability(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node update`)
+
+	assert.Nil(m.SetAvailability("dw1", swarm.AvailabilityPause))
+	assert.Error(m.SetAvailability("dw1", swarm.Availability("paused")))
+	assert.Equal([]string{"docker node update --availability pause dw1"}, runner.Commands(`^docker node update`))
+}
+
+// TestCordon tests that cordoning a node pauses it and uncordoning makes it
+// active again.
+func TestCordon(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node update`)
+
+	assert.Nil(m.Cordon("dw1"))
+	assert.Nil(m.Uncordon("dw1"))
+	assert.Equal([]string{
+		"docker node update --availability pause dw1",
+		"docker node update --availability active dw1",
+	}, runner.Commands(`^docker node update`))
+}
+
+// TestUpdateNode tests that the availability, role and labels of a node are
+// changed with a single update.
+func TestUpdateNode(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node update`)
+
+	assert.Nil(m.UpdateNode("dw1", swarm.NodeSpecUpdate{
+		Availability: swarm.AvailabilityPause,
+		Role:         swarm.ManagerRole,
+		AddLabels:    map[string]string{"zone": "b", "gpu": ""},
+		RemoveLabels: []string{"disk"},
+	}))
+	assert.Nil(m.UpdateNode("dw1", swarm.NodeSpecUpdate{}))
+	assert.Error(m.UpdateNode("dw1", swarm.NodeSpecUpdate{Role: "leader"}))
+	assert.Error(m.UpdateNode("dw9", swarm.NodeSpecUpdate{Role: swarm.WorkerRole}))
+
+	assert.Equal([]string{
+		`docker node update --availability pause --role manager --label-add 'gpu' --label-add 'zone=b' --label-rm 'disk' 2`,
+	}, runner.Commands(`^docker node update`))
+}
+
+// TestUpdateNodeRef tests that a node reference is used as is without
+// listing the cluster's nodes.
+func TestUpdateNodeRef(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node update`)
+
+	assert.Nil(m.UpdateNodeRef("dw1", swarm.NodeSpecUpdate{Availability: swarm.AvailabilityDrain}))
+	assert.Nil(m.UpdateNodeRef("2", swarm.NodeSpecUpdate{AddLabels: map[string]string{"zone": "b"}}))
+	assert.Error(m.UpdateNodeRef("2", swarm.NodeSpecUpdate{Role: "leader"}))
+
+	assert.Equal([]string{
+		`docker node update --availability drain dw1`,
+		`docker node update --label-add 'zone=b' 2`,
+	}, runner.Commands(`^docker node update`))
+	assert.Empty(runner.Commands(`^docker node ls`))
+}
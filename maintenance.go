@@ -0,0 +1,81 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PrepareForMaintenance drains the node with the given hostname and confirms
+// that none of its tasks are still running so it can be safely rebooted or
+// upgraded. The node stays drained until CompleteMaintenance is called.
+func (m *Manager) PrepareForMaintenance(hostname string) error {
+	node, ok, err := m.GetNode(hostname)
+	if err != nil {
+		return fmt.Errorf("error finding node %s: %w", hostname, err)
+	}
+	if !ok {
+		return fmt.Errorf("error node %s not found in cluster", hostname)
+	}
+
+	if _, err := m.DrainNode(node.ID); err != nil {
+		return err
+	}
+
+	tasks, err := m.getTasks(node.ID)
+	if err != nil {
+		return fmt.Errorf("error getting tasks of %s: %w", hostname, err)
+	}
+	if active := tasks.WithoutServices(m.config.DrainIgnoreServices).Active(); active > 0 {
+		return fmt.Errorf("error node %s still has %d tasks running after draining", hostname, active)
+	}
+
+	log.Infof("%s is drained and ready for maintenance", hostname)
+
+	return nil
+}
+
+// CompleteMaintenance makes the node with the given hostname active again
+// after maintenance (see PrepareForMaintenance). An error is returned if the
+// node is not Ready (e.g: it has not finished rebooting).
+func (m *Manager) CompleteMaintenance(hostname string) error {
+	node, ok, err := m.GetNode(hostname)
+	if err != nil {
+		return fmt.Errorf("error finding node %s: %w", hostname, err)
+	}
+	if !ok {
+		return fmt.Errorf("error node %s not found in cluster", hostname)
+	}
+	if !strings.EqualFold(node.Status, "Ready") {
+		return fmt.Errorf("error node %s is %s and not ready", hostname, node.Status)
+	}
+
+	if err := m.SetAvailability(node.ID, AvailabilityActive); err != nil {
+		return err
+	}
+
+	log.Infof("%s is active after maintenance", hostname)
+
+	return nil
+}
@@ -0,0 +1,51 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestMaintenance tests draining a node for maintenance and reactivating it
+// once it is ready again.
+func TestMaintenance(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node ps`)
+	runner.On(`^docker node update`)
+
+	assert.Nil(m.PrepareForMaintenance("dw1"))
+	assert.Nil(m.CompleteMaintenance("dw1"))
+	assert.Equal([]string{
+		"docker node update --availability drain 2",
+		"docker node update --availability active 2",
+	}, runner.Commands(`^docker node update`))
+
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: `{"ID": "2", "Hostname": "dw1", "Status": "Down", "Availability": "Drain"}
+`})
+	assert.Error(m.CompleteMaintenance("dw1"))
+	assert.Error(m.PrepareForMaintenance("dw2"))
+}
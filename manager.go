@@ -24,59 +24,247 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
 	"net"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
-	"go.mills.io/jsonlines"
 
 	"github.com/aucloud/go-runcmd"
 )
 
 const (
-	infoCommand        = `docker info --format "{{ json . }}"`
-	nodesCommand       = `docker node ls --format "{{ json . }}"`
-	tasksCommand       = `docker node ps --format "{{ json .}}" %s`
-	initCommand        = `docker swarm init --advertise-addr %s --listen-addr %s`
-	joinCommand        = `docker swarm join --advertise-addr %s --listen-addr %s --token %s %s:2377`
-	tokenCommand       = `docker swarm join-token -q %s`
-	updateCommand      = `docker node update %s %s`
-	setAvailability    = `--availability %s`
-	labelAdd           = `--label-add %s`
-	availabilityDrain  = `drain`
-	availabilityActive = `active`
+	infoCommand       = `docker info --format "{{ json . }}"`
+	versionCommand    = `docker version --format "{{ .Server.Version }}"`
+	nodesCommand      = `docker node ls --format "{{ json . }}"`
+	tasksCommand      = `docker node ps --format "{{ json .}}" %s`
+	serviceTasks      = `docker service ps --filter desired-state=running --format "{{ json . }}" %s`
+	serviceForce      = `docker service update --force --detach %s`
+	servicesCommand   = `docker service ls --format "{{ json . }}"`
+	networksCommand   = `docker network ls --filter scope=swarm --format "{{ json . }}"`
+	noTrunc           = `--no-trunc`
+	inspectCommand    = `docker node inspect --format "{{ json . }}" %s`
+	selfStatusCommand = `docker node inspect --format "{{ json .ManagerStatus }}" self`
+	initCommand       = `docker swarm init --advertise-addr %s --listen-addr %s`
+	joinCommand       = `docker swarm join --advertise-addr %s --listen-addr %s --token %s`
+	joinAddr          = `%s:2377`
+	leaveCommand      = `docker swarm leave --force`
+	dataPathAddr      = `--data-path-addr %s`
+	reachableCommand  = `nc -z -w 5 %s 2377`
+	tokenCommand      = `docker swarm join-token -q %s`
+	updateCommand     = `docker node update %s %s`
+	demoteCommand     = `docker node demote %s`
+	promoteCommand    = `docker node promote %s`
+	removeCommand     = `docker node rm --force %s`
+	setAvailability   = `--availability %s`
+	labelAdd          = `--label-add %s`
+	labelRm           = `--label-rm %s`
+	setRole           = `--role %s`
 
 	managerToken = "manager"
 	workerToken  = "worker"
 
 	drainTimeout = time.Minute * 10 // 10 minutes
+
+	// leaderTimeout is how long to wait for the managers to elect a
+	// leader after an operation that can trigger an election
+	leaderTimeout = time.Minute * 2
+
+	// drainPollMin and drainPollMax bound the interval between polls of a
+	// draining node's tasks. The interval starts at drainPollMin, doubles
+	// while the node isn't making progress and is reset whenever the
+	// number of remaining tasks decreases.
+	drainPollMin = time.Second * 2
+	drainPollMax = time.Second * 30
 )
 
 const (
 	DefaultTimeout = time.Minute * 5
+
+	// DefaultJoinTimeout is the default time to wait for a node to join
+	DefaultJoinTimeout = time.Minute * 2
+
+	// DefaultDrainMaxFailures is the default number of consecutive failures
+	// to get a draining node's tasks before the drain is aborted
+	DefaultDrainMaxFailures = 5
+
+	// DefaultConcurrency is the default number of nodes operated on at the
+	// same time by operations that can run concurrently
+	DefaultConcurrency = 4
+
+	// MinDockerVersion is the oldest version of Docker that is supported
+	MinDockerVersion = "19.03.0"
+
+	// alreadyInSwarm is part of the error Docker reports when joining a node
+	// that is already part of a swarm
+	alreadyInSwarm = "This node is already part of a swarm"
+
+	// joinAvailabilityVersion is the oldest version of Docker that supports
+	// `docker swarm join --availability`
+	joinAvailabilityVersion = "17.06.0"
 )
 
 type Config struct {
 	Timeout time.Duration
+
+	// InfoCacheTTL is how long the result of GetInfo is cached for the
+	// current node. Zero (the default) disables caching.
+	InfoCacheTTL time.Duration
+
+	// Preflight if true checks that every joining node can reach the swarm
+	// port of the manager it will join before attempting any joins.
+	Preflight bool
+
+	// LeaderFilter if set restricts the manager chosen to initialize a new
+	// cluster to those managers for which it returns true.
+	LeaderFilter func(VMNode) bool
+
+	// Concurrency is the maximum number of nodes operated on at the same
+	// time when the Switcher implements Cloner. A value of 1 or less
+	// operates on one node at a time.
+	Concurrency int
+
+	// Env are environment variables set for every command run on a node
+	// (e.g: DOCKER_HOST or HTTPS_PROXY).
+	Env map[string]string
+
+	// Progress if set is called at each milestone of CreateSwarm and
+	// UpdateSwarm.
+	Progress func(ProgressEvent)
+
+	// SkipQuorumCheck if true allows operations that drain or remove nodes
+	// even if a majority of the managers are not reachable.
+	SkipQuorumCheck bool
+
+	// DrainMaxFailures is the number of consecutive failures to get a
+	// draining node's tasks before the drain is aborted.
+	DrainMaxFailures int
+
+	// DockerPath is the path of the docker binary on nodes. The default
+	// runs `docker` from the PATH.
+	DockerPath string
+
+	// CommandPrefix is prepended to every command run on a node (e.g:
+	// `sudo` for nodes where the user is not in the docker group).
+	CommandPrefix string
+
+	// DrainWaitHealthy if true waits after a node has drained until the
+	// tasks of the services evicted from it are running on other nodes.
+	DrainWaitHealthy bool
+
+	// JoinTimeout is how long to wait for a node to join a swarm before
+	// warning the join is slow. The join is waited for regardless and the
+	// node is only reset if it did not join. Zero never warns.
+	JoinTimeout time.Duration
+
+	// DrainForce if true force updates the services with tasks on a node
+	// after setting it to drain so their tasks are rescheduled.
+	DrainForce bool
+
+	// SkipLabeling if true does not label nodes when creating or updating
+	// a swarm.
+	SkipLabeling bool
+
+	// FailureLogLevel is the level failed commands are logged at
+	FailureLogLevel log.Level
+
+	// DrainCapacityCheck if true checks that the remaining nodes have
+	// enough free resources for the tasks of the nodes being drained
+	DrainCapacityCheck bool
+
+	// ContinueOnWorkerError if true does not abort creating or updating a
+	// swarm when workers fail to join. The failures are returned as a
+	// *WorkerJoinError once the rest of the cluster is complete.
+	ContinueOnWorkerError bool
+
+	// TokenProvider if set provides the join tokens used to join nodes
+	// instead of fetching them from a manager.
+	TokenProvider TokenProvider
+
+	// VersionSkewTolerance is the number of major versions a node's Docker
+	// version may differ from the leader's before it is flagged by
+	// CheckVersionSkew.
+	VersionSkewTolerance int
+
+	// NoTrunc if true lists tasks with their full IDs rather than the
+	// truncated IDs `docker node ps` and `docker service ps` show by default.
+	NoTrunc bool
+
+	// DrainIgnoreServices are the names of services whose tasks are
+	// ignored when draining nodes (e.g: global monitoring agents that are
+	// never rescheduled).
+	DrainIgnoreServices []string
+
+	// AdvertisePublic if true makes nodes advertise and listen on their
+	// PublicAddress and join managers on it instead of the PrivateAddress
+	// (e.g: for nodes in different datacenters without a shared private
+	// network).
+	AdvertisePublic bool
+
+	// PostJoinHooks are run in order after nodes join a swarm. The default
+	// is DefaultPostJoinHooks.
+	PostJoinHooks []PostJoinHook
+
+	// ManagerCount if non-zero is the number of managers CreateSwarm picks
+	// from the nodes tagged as managers when more are tagged. The others
+	// are joined as workers.
+	ManagerCount int
+
+	// WaitForReady if non-zero is how long CreateSwarm waits after joining
+	// nodes for every node that joined to be Ready
+	WaitForReady time.Duration
+
+	// DiagnosticCommands are the commands RunDiagnostic may run. The default
+	// is DefaultDiagnosticCommands.
+	DiagnosticCommands []string
+
+	// ProbeTimeout if non-zero is how long switching to each of a worker's
+	// remote managers may take when looking for a manager before trying the
+	// next one. The default is Timeout.
+	ProbeTimeout time.Duration
+
+	// Events if not nil is the channel Events are published to
+	Events chan<- Event
 }
 
 func NewDefaultConfig() *Config {
 	return &Config{
-		Timeout: DefaultTimeout,
+		Timeout:          DefaultTimeout,
+		Concurrency:      DefaultConcurrency,
+		DrainMaxFailures: DefaultDrainMaxFailures,
+		JoinTimeout:      DefaultJoinTimeout,
+		FailureLogLevel:  log.ErrorLevel,
+		PostJoinHooks:    DefaultPostJoinHooks(),
+
+		DiagnosticCommands: DefaultDiagnosticCommands(),
 	}
 }
 
 // Manager manages all operations of a Docker Swarm cluster with flexible
 // Switcher implementations that permit talking to Docker Nodes over different
 // types of transport (e.g: local or remote).
+//
+// A Manager is not safe for concurrent use as operations switch its Switcher
+// between nodes. Use Clone to get a Manager for each goroutine.
 type Manager struct {
 	config   *Config
 	switcher Switcher
+
+	// node is the node currently switched to
+	node currentNode
+
+	info infoCache
+
+	// versionWarned records the nodes already warned about an unsupported
+	// Docker version so the warning is only logged once per node
+	versionWarned sync.Map
 }
 
 type Option func(*Config) error
@@ -88,269 +276,1808 @@ func WithTimeout(timeout time.Duration) Option {
 	}
 }
 
-// NewManager constructs a new Manager type with the provider Switcher
-func NewManager(switcher Switcher, options ...Option) (*Manager, error) {
-	m := &Manager{switcher: switcher, config: NewDefaultConfig()}
-
-	for _, opt := range options {
-		if err := opt(m.config); err != nil {
-			log.WithError(err).Error("error configuring swarm manager")
-			return nil, err
+// WithConcurrency sets the maximum number of nodes operated on at the same
+// time by operations that can run concurrently.
+func WithConcurrency(n int) Option {
+	return func(cfg *Config) error {
+		if n < 1 {
+			return fmt.Errorf("invalid concurrency %d: must be at least 1", n)
 		}
+		cfg.Concurrency = n
+		return nil
 	}
-
-	return m, nil
 }
 
-// Switcher returns the current Switcher for the manager being used
-func (m *Manager) Switcher() Switcher {
-	return m.switcher
+// WithEnv sets environment variables for every command run on a node. This
+// can be used to target a remote Docker daemon with DOCKER_HOST and
+// DOCKER_CERT_PATH without a custom Switcher. Calling WithEnv more than once
+// merges the variables.
+func WithEnv(env map[string]string) Option {
+	return func(cfg *Config) error {
+		if cfg.Env == nil {
+			cfg.Env = make(map[string]string)
+		}
+		for key, value := range env {
+			if key == "" || strings.ContainsAny(key, "= \t\n") {
+				return fmt.Errorf("invalid environment variable name %q", key)
+			}
+			cfg.Env[key] = value
+		}
+		return nil
+	}
 }
 
-// Runner returns the current Runner for the current Switcher being used
-func (m *Manager) Runner() runcmd.Runner {
-	return m.Switcher().Runner()
+// WithProgress sets a callback that is called at each milestone of
+// CreateSwarm and UpdateSwarm (e.g: to render a progress bar). The callback
+// is called synchronously and should return quickly.
+func WithProgress(fn func(ProgressEvent)) Option {
+	return func(cfg *Config) error {
+		cfg.Progress = fn
+		return nil
+	}
 }
 
-// SwitchNode switches to a new node given by nodeAddr to perform operations on
-func (m *Manager) SwitchNode(nodeAddr string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
-	defer cancel()
-	if err := m.Switcher().Switch(ctx, nodeAddr); err != nil {
-		log.WithError(err).Errorf("error switching to node %s", nodeAddr)
-		return fmt.Errorf("error switching to node %s: %s", nodeAddr, err)
+// WithSkipQuorumCheck allows operations that drain or remove nodes to run
+// even if a majority of the managers are not reachable. This should only be
+// used to recover a cluster that has already lost quorum.
+func WithSkipQuorumCheck() Option {
+	return func(cfg *Config) error {
+		cfg.SkipQuorumCheck = true
+		return nil
 	}
+}
 
-	return nil
+// WithDrainMaxFailures sets the number of consecutive failures to get a
+// draining node's tasks (e.g: because the manager is unreachable) before the
+// drain is aborted rather than waiting for the drain timeout.
+func WithDrainMaxFailures(n int) Option {
+	return func(cfg *Config) error {
+		if n < 1 {
+			return fmt.Errorf("invalid drain max failures %d: must be at least 1", n)
+		}
+		cfg.DrainMaxFailures = n
+		return nil
+	}
 }
 
-// SwitchNodeVia switches to a new node given by nodeAddr by jumping through
-// the current node as a "bastion" host to perform operations on the node.
-func (m *Manager) SwitchNodeVia(nodeAddr string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
-	defer cancel()
-	if err := m.Switcher().SwitchVia(ctx, nodeAddr); err != nil {
-		log.WithError(err).Errorf("error switching to node %s via %s", nodeAddr, m.Switcher())
-		return fmt.Errorf("error switching to node %s via %s: %s", nodeAddr, m.Switcher(), err)
+// WithDockerPath sets the path of the docker binary on nodes where it is not
+// on the PATH.
+func WithDockerPath(path string) Option {
+	return func(cfg *Config) error {
+		cfg.DockerPath = path
+		return nil
 	}
+}
 
-	return nil
+// WithCommandPrefix sets a prefix for every command run on a node (e.g:
+// `sudo` or `sudo -n`) for nodes where the user cannot run docker directly.
+func WithCommandPrefix(prefix string) Option {
+	return func(cfg *Config) error {
+		cfg.CommandPrefix = strings.TrimSpace(prefix)
+		return nil
+	}
 }
 
-func (m *Manager) runCmd(cmd string, args ...string) (io.Reader, error) {
-	if m.Runner() == nil {
-		return nil, fmt.Errorf("error no runner configured")
+// WithDrainWaitHealthy waits after a node has drained until every task of
+// the services that were evicted from it is running on other nodes, rather
+// than only waiting for the tasks on the node to shut down. This permits
+// zero-downtime maintenance.
+func WithDrainWaitHealthy() Option {
+	return func(cfg *Config) error {
+		cfg.DrainWaitHealthy = true
+		return nil
 	}
+}
 
-	log.WithField("args", args).Debugf("running cmd on %s: %s", m.switcher.String(), cmd)
+// WithJoinTimeout sets how long to wait for a node to join a swarm before
+// warning the join is slow. Zero never warns.
+func WithJoinTimeout(timeout time.Duration) Option {
+	return func(cfg *Config) error {
+		cfg.JoinTimeout = timeout
+		return nil
+	}
+}
 
-	worker, err := m.Runner().Command(cmd)
-	if err != nil {
-		return nil, fmt.Errorf("error creating worker: %w", err)
+// WithDrainForce force updates (`docker service update --force`) the
+// services with tasks on a node after setting the node to drain so that
+// tasks that would otherwise not be rescheduled (e.g: one-shot tasks) are.
+// Tasks still on the node if the drain times out are reported in the
+// DrainResult.
+func WithDrainForce() Option {
+	return func(cfg *Config) error {
+		cfg.DrainForce = true
+		return nil
 	}
+}
 
-	stdout := &bytes.Buffer{}
-	worker.SetStdout(stdout)
+// WithoutLabeling skips labeling nodes from their Clusterfile labels when
+// creating or updating a swarm, for when labels are applied separately
+// (e.g: with ApplyLabels). This saves a GetInfo and `docker node update`
+// per node.
+func WithoutLabeling() Option {
+	return func(cfg *Config) error {
+		cfg.SkipLabeling = true
+		return nil
+	}
+}
 
-	stderr := &bytes.Buffer{}
-	worker.SetStderr(stderr)
+// WithFailureLogLevel sets the level failed commands are logged at along
+// with their output (default error). The error is returned to the caller
+// regardless so callers that handle failures themselves can lower the level
+// (e.g: to debug) to avoid noisy logs. Commands that probe a node (e.g:
+// GetInfo and Ping) are always logged at debug level.
+func WithFailureLogLevel(level log.Level) Option {
+	return func(cfg *Config) error {
+		if level < log.ErrorLevel {
+			return fmt.Errorf("invalid failure log level %s: must be error or lower", level)
+		}
+		cfg.FailureLogLevel = level
+		return nil
+	}
+}
 
-	if err := worker.Start(); err != nil {
-		return nil, fmt.Errorf("error starting worker: %w", err)
+// WithDrainCapacityCheck checks before draining nodes that the remaining
+// active nodes have enough free CPU and memory in total for the resources
+// reserved by the tasks on the nodes being drained so tasks aren't left
+// pending indefinitely. A *CapacityError is returned if not.
+func WithDrainCapacityCheck() Option {
+	return func(cfg *Config) error {
+		cfg.DrainCapacityCheck = true
+		return nil
 	}
+}
 
-	if err := worker.Wait(); err != nil {
-		log.WithError(err).
-			WithField("stdout", string(stdout.String())).
-			WithField("stderr", string(stderr.String())).
-			Error("error running worker")
-		return nil, fmt.Errorf(
-			"error running worker: %w (stderr=%q stdout=%q)",
-			err, stderr.String(), stdout.String(),
-		)
+// WithInfoCacheTTL caches the result of GetInfo for the current node for the
+// given duration. The cache is invalidated whenever the Manager switches
+// nodes or runs a command that modifies the cluster.
+func WithInfoCacheTTL(ttl time.Duration) Option {
+	return func(cfg *Config) error {
+		cfg.InfoCacheTTL = ttl
+		return nil
 	}
+}
 
-	return stdout, nil
+// WithPreflight enables checking that every joining node can reach the swarm
+// port (2377) of the manager it will join before attempting any joins.
+func WithPreflight() Option {
+	return func(cfg *Config) error {
+		cfg.Preflight = true
+		return nil
+	}
 }
 
-func (m *Manager) ensureManager() error {
-	node, err := m.GetInfo()
-	if err != nil {
-		return fmt.Errorf("error getting node info: %w", err)
+// WithLeaderFilter restricts the manager chosen to initialize a new cluster
+// to managers matching the given predicate. A random manager is chosen from
+// those that match.
+func WithLeaderFilter(filter func(VMNode) bool) Option {
+	return func(cfg *Config) error {
+		cfg.LeaderFilter = filter
+		return nil
 	}
-	if !node.IsManager() {
-		for _, remoteManager := range node.Swarm.RemoteManagers {
-			host, _, err := net.SplitHostPort(remoteManager.Addr)
-			if err != nil {
-				log.WithError(err).Warn("error parsing remote manager address (trying next manager): %w", err)
-				continue
-			}
-			if err := m.SwitchNodeVia(host); err != nil {
-				log.WithError(err).Warn("error switching to remote manager (trying next manager): %w", err)
-				continue
-			}
-			return nil
+}
+
+// WithPreferredLeaderLabel restricts the manager chosen to initialize a new
+// cluster to managers labelled with the given key and value in their
+// LabelsTag (e.g: `zone=syd1`).
+func WithPreferredLeaderLabel(key, value string) Option {
+	return WithLeaderFilter(func(vm VMNode) bool {
+		labels, err := vm.Labels()
+		if err != nil {
+			return false
 		}
-		return fmt.Errorf("unable to connect to suitable manager")
+		return HasString(labels[key], value)
+	})
+}
+
+// WithContinueOnWorkerError completes creating or updating a swarm even if
+// some workers fail to join. The workers that failed are not labelled and a
+// *WorkerJoinError listing them is returned after the rest of the cluster is
+// complete. Managers failing to join are still fatal.
+func WithContinueOnWorkerError() Option {
+	return func(cfg *Config) error {
+		cfg.ContinueOnWorkerError = true
+		return nil
 	}
+}
 
-	return nil
+// WithTokenProvider uses p for the join tokens of joining nodes instead of
+// fetching them from a manager with `docker swarm join-token`. The tokens
+// must be those of the cluster being joined.
+func WithTokenProvider(p TokenProvider) Option {
+	return func(cfg *Config) error {
+		cfg.TokenProvider = p
+		return nil
+	}
 }
 
-func (m *Manager) joinSwarm(newNode VMNode, managerNode VMNode, token string) error {
-	if err := m.SwitchNode(newNode.PublicAddress); err != nil {
-		return fmt.Errorf("error switching nodes to %s: %w", newNode.PublicAddress, err)
+// WithVersionSkewTolerance sets the number of major versions a node's
+// Docker version may differ from the leader's before CheckVersionSkew flags
+// it. The default of 0 flags any node with a different major version.
+func WithVersionSkewTolerance(n int) Option {
+	return func(cfg *Config) error {
+		if n < 0 {
+			return fmt.Errorf("invalid version skew tolerance %d: must not be negative", n)
+		}
+		cfg.VersionSkewTolerance = n
+		return nil
 	}
+}
 
-	cmd := fmt.Sprintf(
-		joinCommand,
-		newNode.PrivateAddress,
-		newNode.PrivateAddress,
-		token,
-		managerNode.PrivateAddress,
-	)
-	_, err := m.runCmd(cmd)
-	if err != nil {
-		return fmt.Errorf("error running join command: %w", err)
+// WithNoTrunc lists tasks (e.g: in DrainResult and GetTasks) with their full
+// IDs instead of truncated IDs which can be ambiguous in large clusters.
+func WithNoTrunc() Option {
+	return func(cfg *Config) error {
+		cfg.NoTrunc = true
+		return nil
 	}
+}
 
-	return nil
+// WithDrainIgnoreServices ignores the tasks of the given services when
+// draining nodes so a drain completes once every other task has shut down.
+// This is useful for services deployed globally (e.g: monitoring agents)
+// whose tasks are never rescheduled and would otherwise fail the drain.
+func WithDrainIgnoreServices(services ...string) Option {
+	return func(cfg *Config) error {
+		cfg.DrainIgnoreServices = append(cfg.DrainIgnoreServices, services...)
+		return nil
+	}
 }
 
-func (m *Manager) LabelNode(node VMNode) error {
-	if err := m.SwitchNode(node.PublicAddress); err != nil {
-		return fmt.Errorf("error switching nodes to %s: %w", node, err)
+// WithAdvertisePublicAddress makes every node advertise and listen on its
+// PublicAddress and join the managers on theirs instead of using the
+// PrivateAddress or AdvertiseInterface. This is for clusters spanning
+// datacenters without a shared private network. All nodes must then have a
+// public IP address as a cluster cannot mix public and private addresses.
+func WithAdvertisePublicAddress() Option {
+	return func(cfg *Config) error {
+		cfg.AdvertisePublic = true
+		return nil
 	}
+}
 
-	info, err := m.GetInfo()
-	if err != nil {
-		return fmt.Errorf("error getting node info: %w", err)
+// WithPostJoinHooks adds hooks that are run after the default hooks (see
+// DefaultPostJoinHooks) once nodes have joined a swarm (e.g: to apply
+// settings from custom Clusterfile tags).
+func WithPostJoinHooks(hooks ...PostJoinHook) Option {
+	return func(cfg *Config) error {
+		cfg.PostJoinHooks = append(cfg.PostJoinHooks, hooks...)
+		return nil
 	}
+}
 
-	labelOptions := []string{}
+// WithManagerCount makes CreateSwarm pick n (3 or 5) managers when more
+// nodes than that are tagged as managers in the Clusterfile, treating the
+// tag as "manager-capable". The managers are picked in order of hostname so
+// the same nodes are picked every time and the others are joined as workers.
+// Note that Clusterfile.Validate still requires 3 or 5 managers to be tagged.
+func WithManagerCount(n int) Option {
+	return func(cfg *Config) error {
+		if !(n == 3 || n == 5) {
+			return fmt.Errorf("invalid manager count %d: expected 3 or 5", n)
+		}
+		cfg.ManagerCount = n
+		return nil
+	}
+}
 
-	labels, err := ParseLabels(node.GetTag(LabelsTag))
-	if err != nil {
-		log.WithError(err).Error("error parsing labels")
-		return fmt.Errorf("error parsing labels: %w", err)
+// WithWaitForReady makes CreateSwarm wait up to timeout after joining nodes
+// until every node that joined is Ready so the cluster can accept
+// deployments as soon as CreateSwarm returns.
+func WithWaitForReady(timeout time.Duration) Option {
+	return func(cfg *Config) error {
+		if timeout <= 0 {
+			return fmt.Errorf("invalid wait for ready timeout %s: must be positive", timeout)
+		}
+		cfg.WaitForReady = timeout
+		return nil
 	}
+}
 
-	if labels == nil || len(labels) == 0 {
-		// No labels, nothing to do.
+// WithDiagnosticCommands replaces the commands RunDiagnostic may run (see
+// DefaultDiagnosticCommands). Commands must match exactly so they should
+// include any arguments.
+func WithDiagnosticCommands(commands ...string) Option {
+	return func(cfg *Config) error {
+		cfg.DiagnosticCommands = commands
 		return nil
 	}
+}
 
-	for key, values := range labels {
-		label := key
-		if values != nil || len(values) > 0 {
-			label += fmt.Sprintf("=%s", strings.Join(values, ","))
+// WithProbeTimeout sets how long switching to each of a worker's remote
+// managers may take when looking for a manager to run commands on before
+// trying the next one, separately from the Timeout of other switches. A
+// short timeout fails over quickly when the first manager is down.
+func WithProbeTimeout(timeout time.Duration) Option {
+	return func(cfg *Config) error {
+		if timeout <= 0 {
+			return fmt.Errorf("invalid probe timeout %s: must be positive", timeout)
 		}
-		labelOptions = append(labelOptions, fmt.Sprintf(labelAdd, label))
+		cfg.ProbeTimeout = timeout
+		return nil
 	}
+}
 
-	if err := m.ensureManager(); err != nil {
-		return fmt.Errorf("error connecting to manager node: %w", err)
+// WithEventChannel publishes an Event to events at each step of operations
+// such as nodes joining, the progress of draining nodes and failures (e.g:
+// to drive a live UI). Events are sent without blocking and are dropped if
+// events is full so it should be buffered and drained promptly.
+func WithEventChannel(events chan<- Event) Option {
+	return func(cfg *Config) error {
+		cfg.Events = events
+		return nil
 	}
+}
 
-	cmd := fmt.Sprintf(
-		updateCommand,
-		strings.Join(labelOptions, " "),
-		info.Swarm.NodeID,
-	)
-	_, err = m.runCmd(cmd)
-	if err != nil {
-		return fmt.Errorf("error running update command: %w", err)
+// NewManager constructs a new Manager type with the provider Switcher
+func NewManager(switcher Switcher, options ...Option) (*Manager, error) {
+	m := &Manager{switcher: switcher, config: NewDefaultConfig()}
+
+	for _, opt := range options {
+		if err := opt(m.config); err != nil {
+			log.WithError(err).Error("error configuring swarm manager")
+			return nil, err
+		}
 	}
 
-	return nil
+	return m, nil
 }
 
-// GetInfo returns information about the current node
-func (m *Manager) GetInfo() (NodeInfo, error) {
-	var node NodeInfo
+// clone returns a new Manager with the same configuration and an independent
+// copy of the Switcher. ok is false if the Switcher does not implement
+// Cloner.
+func (m *Manager) clone() (*Manager, bool) {
+	cloner, ok := m.Switcher().(Cloner)
+	if !ok {
+		return nil, false
+	}
+	return &Manager{switcher: cloner.Clone(), config: m.config}, true
+}
 
-	cmd := infoCommand
-	out, err := m.runCmd(cmd)
-	if err != nil {
-		return NodeInfo{}, fmt.Errorf("error running info command: %w", err)
+// Clone returns a new Manager with the same configuration and its own
+// Switcher connection switched to the same node as m (if any) so that it can
+// be used in another goroutine. ErrNotCloneable is returned if the Switcher
+// does not implement Cloner.
+func (m *Manager) Clone() (*Manager, error) {
+	c, ok := m.clone()
+	if !ok {
+		return nil, ErrNotCloneable
 	}
 
-	data, err := ioutil.ReadAll(out)
-	if err != nil {
-		return NodeInfo{}, fmt.Errorf("error reading info command output: %w", err)
+	if err := c.restoreNode(m.node); err != nil {
+		return nil, fmt.Errorf("error switching clone to %s: %w", m.node.addr, err)
 	}
 
-	if err := json.Unmarshal(data, &node); err != nil {
-		return NodeInfo{}, fmt.Errorf("error parsing json data: %s", err)
+	return c, nil
+}
+
+// Switcher returns the current Switcher for the manager being used
+func (m *Manager) Switcher() Switcher {
+	return m.switcher
+}
+
+// Runner returns the current Runner for the current Switcher being used or
+// nil if there is none
+func (m *Manager) Runner() runcmd.Runner {
+	if m.switcher == nil {
+		return nil
 	}
+	return m.switcher.Runner()
+}
 
-	return node, nil
+// currentNode is the node a Manager is switched to and whether it was
+// switched to via another node
+type currentNode struct {
+	addr string
+	via  bool
 }
 
-// GetManagers returns a list of manager nodes and their information
-func (m *Manager) GetManagers() ([]NodeInfo, error) {
-	node, err := m.GetInfo()
-	if err != nil {
-		return nil, fmt.Errorf("error getting node info: %w", err)
+// CurrentNode returns the address of the node currently switched to or an
+// empty string if the Manager has not switched to any node.
+func (m *Manager) CurrentNode() string {
+	return m.node.addr
+}
+
+// SwitchNode switches to a new node given by nodeAddr to perform operations on
+func (m *Manager) SwitchNode(nodeAddr string) error {
+	if m.switcher == nil {
+		return fmt.Errorf("error switching to node %s: %w", nodeAddr, ErrNoRunner)
 	}
 
-	var managers []NodeInfo
-	for _, remoteManager := range node.Swarm.RemoteManagers {
-		host, _, err := net.SplitHostPort(remoteManager.Addr)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing remote manager address: %w", err)
-		}
-		if err := m.SwitchNode(host); err != nil {
-			return nil, fmt.Errorf("error switching nodes to %s: %w", host, err)
-		}
-		node, err := m.GetInfo()
-		if err != nil {
-			return nil, fmt.Errorf("error getting manager node info: %w", err)
-		}
-		managers = append(managers, node)
+	m.info.invalidate()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	defer cancel()
+	if err := m.Switcher().Switch(ctx, nodeAddr); err != nil {
+		log.WithError(err).Errorf("error switching to node %s", nodeAddr)
+		return fmt.Errorf("error switching to node %s: %s", nodeAddr, err)
+	}
+	if m.Runner() == nil {
+		return fmt.Errorf("error switching to node %s: %w", nodeAddr, ErrNoRunner)
 	}
 
-	return managers, nil
+	m.node = currentNode{addr: nodeAddr}
+
+	return nil
 }
 
-// GetNodes returns all nodes in the cluster
-func (m *Manager) GetNodes() ([]NodeStatus, error) {
-	if err := m.ensureManager(); err != nil {
-		return nil, fmt.Errorf("error connecting to manager node: %w", err)
+// SwitchNodeVia switches to a new node given by nodeAddr by jumping through
+// the current node as a "bastion" host to perform operations on the node.
+func (m *Manager) SwitchNodeVia(nodeAddr string) error {
+	return m.switchNodeVia(nodeAddr, m.config.Timeout)
+}
+
+// switchNodeVia is SwitchNodeVia with the given timeout for the switch
+func (m *Manager) switchNodeVia(nodeAddr string, timeout time.Duration) error {
+	if m.switcher == nil {
+		return fmt.Errorf("error switching to node %s: %w", nodeAddr, ErrNoRunner)
+	}
+
+	m.info.invalidate()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := m.Switcher().SwitchVia(ctx, nodeAddr); err != nil {
+		log.WithError(err).Errorf("error switching to node %s via %s", nodeAddr, m.Switcher())
+		return fmt.Errorf("error switching to node %s via %s: %s", nodeAddr, m.Switcher(), err)
+	}
+	if m.Runner() == nil {
+		return fmt.Errorf("error switching to node %s via %s: %w", nodeAddr, m.Switcher(), ErrNoRunner)
+	}
+
+	m.node = currentNode{addr: nodeAddr, via: true}
+
+	return nil
+}
+
+// restoreNode switches back to a node previously switched to
+func (m *Manager) restoreNode(node currentNode) error {
+	if node.addr == "" || node == m.node {
+		return nil
+	}
+	if node.via {
+		return m.SwitchNodeVia(node.addr)
+	}
+	return m.SwitchNode(node.addr)
+}
+
+// preserveNode runs fn and then switches back to the node that was current
+// before fn was run. Operations that switch between nodes should be run with
+// preserveNode (or withNode) so the Manager isn't left pointing at an
+// unexpected node.
+func (m *Manager) preserveNode(fn func() error) error {
+	prev := m.node
+
+	err := fn()
+
+	if restoreErr := m.restoreNode(prev); restoreErr != nil {
+		if err != nil {
+			log.WithError(restoreErr).Errorf("error switching back to %s", prev.addr)
+			return err
+		}
+		return fmt.Errorf("error switching back to %s: %w", prev.addr, restoreErr)
+	}
+
+	return err
+}
+
+// withNode switches to the node at nodeAddr, runs fn and then switches back
+// to the node that was current before.
+func (m *Manager) withNode(nodeAddr string, fn func() error) error {
+	return m.preserveNode(func() error {
+		if err := m.SwitchNode(nodeAddr); err != nil {
+			return fmt.Errorf("error switching nodes to %s: %w", nodeAddr, err)
+		}
+		return fn()
+	})
+}
+
+// prepareCmd returns the command to run on a node with the configured
+// docker path, environment variables and command prefix applied.
+func (m *Manager) prepareCmd(cmd string) string {
+	if m.config.DockerPath != "" && strings.HasPrefix(cmd, "docker ") {
+		cmd = m.config.DockerPath + strings.TrimPrefix(cmd, "docker")
+	}
+
+	var args []string
+
+	if m.config.CommandPrefix != "" {
+		args = append(args, m.config.CommandPrefix)
+	}
+
+	if len(m.config.Env) > 0 {
+		keys := make([]string, 0, len(m.config.Env))
+		for key := range m.config.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		args = append(args, "env")
+		for _, key := range keys {
+			args = append(args, fmt.Sprintf("%s=%s", key, ShellQuote(m.config.Env[key])))
+		}
+	}
+
+	return strings.Join(append(args, cmd), " ")
+}
+
+// cmdResult is the result of a command that exited successfully. Anything
+// the command wrote to stderr is surfaced as Warnings as Docker writes
+// deprecation warnings and informational messages to stderr on success.
+type cmdResult struct {
+	Stdout   *bytes.Buffer
+	Warnings []string
+}
+
+// execCmd runs the command on the current node. Only a non-zero exit status
+// (or failure to run the command) is treated as an error and is logged at
+// the given level.
+func (m *Manager) execCmd(level log.Level, cmd string, args ...string) (cmdResult, error) {
+	if m.Runner() == nil {
+		return cmdResult{}, ErrNoRunner
+	}
+
+	log.WithField("args", args).Debugf("running cmd on %s: %s", m.switcher.String(), cmd)
+
+	worker, err := m.Runner().Command(m.prepareCmd(cmd))
+	if err != nil {
+		return cmdResult{}, fmt.Errorf("error creating worker: %w", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	worker.SetStdout(stdout)
+
+	stderr := &bytes.Buffer{}
+	worker.SetStderr(stderr)
+
+	if err := worker.Start(); err != nil {
+		return cmdResult{}, fmt.Errorf("error starting worker: %w", err)
+	}
+
+	if err := worker.Wait(); err != nil {
+		log.WithError(err).
+			WithField("stdout", string(stdout.String())).
+			WithField("stderr", string(stderr.String())).
+			Log(level, "error running worker")
+		return cmdResult{}, &CommandError{
+			Cmd:    cmd,
+			Code:   exitCode(err),
+			Stdout: stdout.String(),
+			Stderr: stderr.String(),
+			Err:    err,
+		}
+	}
+
+	var warnings []string
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			warnings = append(warnings, line)
+		}
+	}
+
+	return cmdResult{Stdout: stdout, Warnings: warnings}, nil
+}
+
+func (m *Manager) runCmd(cmd string, args ...string) (io.Reader, error) {
+	return m.runCmdLevel(m.config.FailureLogLevel, cmd, args...)
+}
+
+// runProbeCmd runs a command that is expected to fail in normal operation
+// (e.g: probing whether a node is reachable) and logs failures at debug
+// level. The caller is responsible for handling or reporting the error.
+func (m *Manager) runProbeCmd(cmd string, args ...string) (io.Reader, error) {
+	return m.runCmdLevel(log.DebugLevel, cmd, args...)
+}
+
+func (m *Manager) runCmdLevel(level log.Level, cmd string, args ...string) (io.Reader, error) {
+	res, err := m.execCmd(level, cmd, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, warning := range res.Warnings {
+		log.WithField("cmd", cmd).Warnf("warning from %s: %s", m.switcher.String(), warning)
+	}
+
+	return res.Stdout, nil
+}
+
+// runCmdStream runs the command on the current node and returns its stdout
+// as it is produced rather than buffering it. Callers must Close the reader.
+// If the command fails, reading from the reader returns the error.
+func (m *Manager) runCmdStream(cmd string, args ...string) (io.ReadCloser, error) {
+	if m.Runner() == nil {
+		return nil, ErrNoRunner
+	}
+
+	log.WithField("args", args).Debugf("running cmd on %s: %s", m.switcher.String(), cmd)
+
+	worker, err := m.Runner().Command(m.prepareCmd(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("error creating worker: %w", err)
+	}
+
+	stdout, w := io.Pipe()
+	worker.SetStdout(w)
+
+	stderr := &bytes.Buffer{}
+	worker.SetStderr(stderr)
+
+	if err := worker.Start(); err != nil {
+		w.Close()
+		return nil, fmt.Errorf("error starting worker: %w", err)
+	}
+
+	go func() {
+		if err := worker.Wait(); err != nil {
+			log.WithError(err).
+				WithField("stderr", stderr.String()).
+				Log(m.config.FailureLogLevel, "error running worker")
+			w.CloseWithError(&CommandError{Cmd: cmd, Code: exitCode(err), Stderr: stderr.String(), Err: err})
+			return
+		}
+		w.Close()
+	}()
+
+	return stdout, nil
+}
+
+// runMutatingCmd runs a command that modifies the node or cluster and
+// invalidates any cached node information.
+func (m *Manager) runMutatingCmd(cmd string, args ...string) (io.Reader, error) {
+	defer m.info.invalidate()
+	return m.runCmd(cmd, args...)
+}
+
+// runMutatingCmdTimeout is like runMutatingCmd but warns if the command does
+// not complete within timeout. The command itself cannot be interrupted so it
+// is waited for regardless to ensure nothing else (e.g: a reset after a failed
+// join) is run on the node while it is still running, and its result is
+// returned as is. A timeout of zero waits without warning.
+func (m *Manager) runMutatingCmdTimeout(timeout time.Duration, cmd string, args ...string) (io.Reader, error) {
+	if timeout <= 0 {
+		return m.runMutatingCmd(cmd, args...)
+	}
+
+	type result struct {
+		stdout io.Reader
+		err    error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		stdout, err := m.runMutatingCmd(cmd, args...)
+		done <- result{stdout, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-done:
+		return res.stdout, res.err
+	case <-timer.C:
+		log.Warnf("command did not complete within %s, waiting for it to complete", timeout)
+		res := <-done
+		return res.stdout, res.err
+	}
+}
+
+func (m *Manager) ensureManager() error {
+	node, err := m.GetInfo()
+	if err != nil {
+		return fmt.Errorf("error getting node info: %w", err)
+	}
+	if node.IsLocked() {
+		log.Warnf("node %s is locked and must be unlocked with the swarm's unlock key", node.Name)
+	}
+	if !node.IsManager() {
+		if len(node.Swarm.RemoteManagers) == 0 {
+			return fmt.Errorf("unable to connect to suitable manager: no remote managers known")
+		}
+
+		// Managers are tried one at a time as the Switcher is connected to
+		// a single node, each with the (shorter) ProbeTimeout if set
+		timeout := m.config.Timeout
+		if m.config.ProbeTimeout > 0 {
+			timeout = m.config.ProbeTimeout
+		}
+
+		var errs MultiError
+		for _, remoteManager := range node.Swarm.RemoteManagers {
+			host, _, err := net.SplitHostPort(remoteManager.Addr)
+			if err != nil {
+				log.WithError(err).Warn("error parsing remote manager address (trying next manager)")
+				errs = append(errs, fmt.Errorf("manager %s: error parsing address: %w", remoteManager.Addr, err))
+				continue
+			}
+			if err := m.switchNodeVia(host, timeout); err != nil {
+				log.WithError(err).Warn("error switching to remote manager (trying next manager)")
+				errs = append(errs, fmt.Errorf("manager %s: %w", host, err))
+				continue
+			}
+			return nil
+		}
+		return fmt.Errorf("unable to connect to suitable manager: %w", errs)
+	}
+
+	return nil
+}
+
+// checkManagerCount checks that a cluster with n managers can tolerate the
+// loss of a minority of them. An even number of managers tolerates no more
+// failures than one fewer manager while making quorum harder to keep.
+func checkManagerCount(n int) error {
+	if n%2 == 0 {
+		return fmt.Errorf("cluster would have an even number of managers (%d)", n)
+	}
+	if n > 7 {
+		log.Warnf("cluster would have %d managers, more than 7 managers slows down the Raft consensus", n)
+	}
+	return nil
+}
+
+// checkRoles checks that every node has a valid RoleTag as nodes without
+// one would be neither joined as managers nor as workers.
+func checkRoles(vms VMNodes) error {
+	invalid := vms.WithoutRole()
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	var hostnames []string
+	for _, vm := range invalid {
+		hostnames = append(hostnames, vm.Hostname)
+	}
+
+	return fmt.Errorf(
+		"nodes %s have no valid %s tag (expected %s or %s)",
+		strings.Join(hostnames, ","), RoleTag, ManagerRole, WorkerRole,
+	)
+}
+
+// advertisedNodes returns vms with the address each node advertises set to
+// its PublicAddress if AdvertisePublic is set. An error is returned if any
+// node's PublicAddress is not an IP address as it cannot be advertised.
+func (m *Manager) advertisedNodes(vms VMNodes) (VMNodes, error) {
+	if !m.config.AdvertisePublic {
+		return vms, nil
+	}
+
+	res := make(VMNodes, len(vms))
+	for i, vm := range vms {
+		if net.ParseIP(vm.PublicAddress) == nil {
+			return nil, fmt.Errorf("node %s public address %q is not an IP address", vm.Hostname, vm.PublicAddress)
+		}
+		vm.PrivateAddress = vm.PublicAddress
+		vm.AdvertiseInterface = ""
+		res[i] = vm
+	}
+
+	return res, nil
+}
+
+// selectManagers returns vms with only the first n of the nodes tagged as
+// managers (in order of hostname) left as managers and the others tagged as
+// workers. vms is returned as is if n is zero or no more than n nodes are
+// tagged as managers.
+func selectManagers(vms VMNodes, n int) VMNodes {
+	candidates := vms.FilterByTag(RoleTag, ManagerRole)
+	if n == 0 || len(candidates) <= n {
+		return vms
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Hostname < candidates[j].Hostname
+	})
+
+	selected := make(map[string]bool)
+	for _, vm := range candidates[:n] {
+		selected[vm.Hostname] = true
+	}
+
+	var managers, workers []string
+
+	res := make(VMNodes, len(vms))
+	for i, vm := range vms {
+		if vm.GetTag(RoleTag) == ManagerRole {
+			if selected[vm.Hostname] {
+				managers = append(managers, vm.Hostname)
+			} else {
+				tags := make(map[string]string, len(vm.Tags))
+				for key, value := range vm.Tags {
+					tags[key] = value
+				}
+				tags[RoleTag] = WorkerRole
+				vm.Tags = tags
+				workers = append(workers, vm.Hostname)
+			}
+		}
+		res[i] = vm
+	}
+
+	log.Infof(
+		"Selected %s as managers of %d candidates, joining %s as workers",
+		strings.Join(managers, ","), len(candidates), strings.Join(workers, ","),
+	)
+
+	return res
+}
+
+// checkQuorum checks that a majority of the cluster's managers are reachable
+// by querying each of them as GetManagers does. A *QuorumError is returned if
+// not, unless SkipQuorumCheck is set.
+func (m *Manager) checkQuorum() error {
+	if m.config.SkipQuorumCheck {
+		return nil
+	}
+
+	managers, errs, err := m.queryManagers()
+	if err != nil {
+		return fmt.Errorf("error getting managers: %w", err)
+	}
+	for _, err := range errs {
+		log.WithError(err).Warn("manager is unreachable")
+	}
+
+	var reachable int
+	for _, manager := range managers {
+		if manager.IsManager() {
+			reachable++
+		}
+	}
+
+	total := len(managers) + len(errs)
+	if reachable <= total/2 {
+		return &QuorumError{Reachable: reachable, Total: total}
+	}
+
+	return nil
+}
+
+// quorum returns a *QuorumError if fewer than a majority of the managers in
+// nodes are reachable.
+func quorum(nodes Nodes) error {
+	var total, reachable int
+	for _, node := range nodes {
+		switch node.ManagerStatus {
+		case "":
+			continue
+		case "Leader", "Reachable":
+			reachable++
+		}
+		total++
+	}
+
+	if reachable <= total/2 {
+		return &QuorumError{Reachable: reachable, Total: total}
+	}
+
+	return nil
+}
+
+// joinSwarm joins newNode to the swarm of the manager at remoteAddr as the
+// given role with the join token for that role. If the join fails (or times
+// out) and the node was not part of a swarm before, the node is made to
+// leave the swarm again so the join can be retried.
+func (m *Manager) joinSwarm(newNode VMNode, remoteAddr, token string, role Role) error {
+	if err := m.SwitchNode(newNode.PublicAddress); err != nil {
+		return fmt.Errorf("error switching nodes to %s: %w", newNode.PublicAddress, err)
+	}
+
+	node, err := m.GetInfo()
+	if err != nil {
+		return fmt.Errorf("error getting node info: %w", err)
+	}
+	clean := node.Swarm.LocalNodeState == "" || node.Swarm.LocalNodeState == "inactive"
+
+	// Nodes join with their availability if the node supports it so they
+	// aren't assigned tasks before it is set. AvailabilityHook sets it after
+	// joining regardless for older versions.
+	var options []string
+	if availability := newNode.GetTag(AvailabilityTag); availability != "" && availability != string(AvailabilityActive) {
+		if node.ServerVersion != "" && CompareVersions(node.ServerVersion, joinAvailabilityVersion) >= 0 {
+			options = append(options, fmt.Sprintf(setAvailability, availability))
+		} else {
+			log.Debugf("%s does not support joining with an availability, it will be set after joining", newNode.Hostname)
+		}
+	}
+
+	cmd := buildJoinCommand(newNode, remoteAddr, token, options...)
+	stdout, err := m.runMutatingCmdTimeout(m.config.JoinTimeout, cmd)
+	if isAlreadyInSwarm(err) {
+		// e.g: a previous join timed out but the node joined regardless
+		return m.checkJoined(newNode, remoteAddr, role)
+	}
+	if err != nil {
+		if clean {
+			// the join may have failed after the node joined the swarm, in
+			// which case it is left as is rather than reset
+			if joinedErr := m.checkJoined(newNode, remoteAddr, role); joinedErr == nil {
+				log.WithError(err).Warnf("%s joined the swarm despite the join command failing", newNode.Hostname)
+				return nil
+			}
+			log.Warnf("resetting %s after failed join", newNode.Hostname)
+			if _, leaveErr := m.runMutatingCmd(leaveCommand); leaveErr != nil {
+				log.WithError(leaveErr).Errorf("error resetting %s after failed join", newNode.Hostname)
+			}
+		}
+		return fmt.Errorf("error running join command: %w", err)
+	}
+
+	if data, err := ioutil.ReadAll(stdout); err == nil {
+		log.Debugf("%s: %s", newNode.Hostname, strings.TrimSpace(string(data)))
+	}
+
+	return nil
+}
+
+// isAlreadyInSwarm returns true if err is the error Docker reports when a
+// node that is already part of a swarm is joined to a swarm
+func isAlreadyInSwarm(err error) bool {
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	return strings.Contains(cmdErr.Stderr, alreadyInSwarm)
+}
+
+// checkJoined checks that the current node, which Docker reports is already
+// part of a swarm, is a member of the swarm of the manager at remoteAddr (and
+// not of another swarm) with the given role.
+func (m *Manager) checkJoined(newNode VMNode, remoteAddr string, role Role) error {
+	node, err := m.GetInfo()
+	if err != nil {
+		return fmt.Errorf("error getting node info: %w", err)
+	}
+
+	for _, remoteManager := range node.Swarm.RemoteManagers {
+		host, _, err := net.SplitHostPort(remoteManager.Addr)
+		if err != nil {
+			continue
+		}
+		if host != remoteAddr {
+			continue
+		}
+
+		actual := Role(WorkerRole)
+		if node.IsManager() {
+			actual = ManagerRole
+		}
+		if actual != role {
+			return fmt.Errorf("error node %s is already part of the swarm as a %s not a %s", newNode.Hostname, actual, role)
+		}
+
+		log.Infof("%s is already part of the swarm", newNode.Hostname)
+		return nil
+	}
+
+	return fmt.Errorf("error node %s is already part of another swarm", newNode.Hostname)
+}
+
+// joinNodes joins each of the nodes to the swarm of the manager at
+// remoteAddr as the given role, reporting progress with phase, and then
+// switches back to the current node.
+func (m *Manager) joinNodes(nodes VMNodes, remoteAddr, token string, role Role, phase Phase) error {
+	return m.preserveNode(func() error {
+		for i, node := range nodes {
+			if err := m.joinSwarm(node, remoteAddr, token, role); err != nil {
+				m.publishError(node.Hostname, err)
+				return fmt.Errorf("error joining %s to %s: %w", node.PublicAddress, remoteAddr, err)
+			}
+			m.progress(phase, node.Hostname, i+1, len(nodes))
+		}
+		return nil
+	})
+}
+
+// joinWorkers joins workers to the swarm like joinNodes and returns vms. If
+// ContinueOnWorkerError is set a worker failing to join does not stop the
+// others joining: vms is returned without the workers that failed along with
+// a *WorkerJoinError listing them so the rest of the cluster can be
+// completed.
+func (m *Manager) joinWorkers(vms, workers VMNodes, remoteAddr, token string) (VMNodes, *WorkerJoinError, error) {
+	if !m.config.ContinueOnWorkerError {
+		if err := m.joinNodes(workers, remoteAddr, token, WorkerRole, PhaseWorkerJoined); err != nil {
+			return nil, nil, err
+		}
+		return vms, nil, nil
+	}
+
+	joinErr := &WorkerJoinError{Errors: make(map[string]error)}
+
+	err := m.preserveNode(func() error {
+		for i, node := range workers {
+			if err := m.joinSwarm(node, remoteAddr, token, WorkerRole); err != nil {
+				log.WithError(err).Warnf("error joining worker %s to %s (continuing)", node.Hostname, remoteAddr)
+				m.publishError(node.Hostname, err)
+				joinErr.Errors[node.Hostname] = err
+				continue
+			}
+			m.progress(PhaseWorkerJoined, node.Hostname, i+1, len(workers))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(joinErr.Errors) == 0 {
+		return vms, nil, nil
+	}
+
+	var joined VMNodes
+	for _, vm := range vms {
+		if _, failed := joinErr.Errors[vm.Hostname]; !failed {
+			joined = append(joined, vm)
+		}
+	}
+
+	return joined, joinErr, nil
+}
+
+// addNodes joins new nodes to the cluster of the current manager with the
+// join token of the given type ("manager" or "worker") and applies their
+// labels and availability.
+func (m *Manager) addNodes(vms VMNodes, tokenType string, phase Phase) error {
+	vms, err := m.advertisedNodes(vms)
+	if err != nil {
+		return fmt.Errorf("error checking advertise addresses: %w", err)
+	}
+
+	if err := m.ensureManager(); err != nil {
+		return fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	node, err := m.GetInfo()
+	if err != nil {
+		return fmt.Errorf("error getting node info: %w", err)
+	}
+	remoteAddr := node.Swarm.NodeAddr
+
+	if err := m.checkHostnames(vms); err != nil {
+		return fmt.Errorf("error checking node hostnames: %w", err)
+	}
+
+	if m.config.Preflight {
+		if err := m.checkReachable(vms, remoteAddr); err != nil {
+			return fmt.Errorf("error checking connectivity to manager: %w", err)
+		}
+	}
+
+	token, err := m.joinToken(tokenType)
+	if err != nil {
+		return fmt.Errorf("error getting %s join token: %w", tokenType, err)
+	}
+
+	if err := m.joinNodes(vms, remoteAddr, token, Role(tokenType), phase); err != nil {
+		return fmt.Errorf("error joining nodes: %w", err)
+	}
+
+	if err := m.postJoin(vms); err != nil {
+		return fmt.Errorf("error configuring nodes: %w", err)
+	}
+
+	return nil
+}
+
+// checkReachable checks that each of the nodes can reach the swarm port of
+// the manager at addr. An *UnreachableError lists the nodes that cannot.
+func (m *Manager) checkReachable(nodes VMNodes, addr string) error {
+	var unreachable []string
+
+	err := m.preserveNode(func() error {
+		for _, node := range nodes {
+			if err := m.SwitchNode(node.PublicAddress); err != nil {
+				return fmt.Errorf("error switching nodes to %s: %w", node.PublicAddress, err)
+			}
+
+			cmd := fmt.Sprintf(reachableCommand, addr)
+			if _, err := m.runProbeCmd(cmd); err != nil {
+				log.WithError(err).Warnf("node %s cannot reach manager %s", node.Hostname, addr)
+				unreachable = append(unreachable, node.Hostname)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(unreachable) > 0 {
+		return &UnreachableError{Addr: addr, Nodes: unreachable}
+	}
+
+	return nil
+}
+
+// checkHostnames checks that the hostname reported by each node matches the
+// node's hostname in the Clusterfile. A MultiError of *HostnameMismatchError
+// lists the nodes that do not.
+func (m *Manager) checkHostnames(nodes VMNodes) error {
+	var errs MultiError
+
+	err := m.preserveNode(func() error {
+		for _, vm := range nodes {
+			if err := m.SwitchNode(vm.PublicAddress); err != nil {
+				return fmt.Errorf("error switching nodes to %s: %w", vm.PublicAddress, err)
+			}
+
+			node, err := m.GetInfo()
+			if err != nil {
+				return fmt.Errorf("error getting node info from %s: %w", vm.PublicAddress, err)
+			}
+
+			if node.Name != vm.Hostname {
+				errs = append(errs, &HostnameMismatchError{
+					Addr:     vm.PublicAddress,
+					Expected: vm.Hostname,
+					Actual:   node.Name,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// buildInitCommand builds the command to initialize a new swarm on node
+func buildInitCommand(node VMNode) string {
+	args := []string{fmt.Sprintf(initCommand, node.AdvertiseAddr(), node.AdvertiseAddr())}
+
+	if node.DataPathAddress != "" {
+		args = append(args, fmt.Sprintf(dataPathAddr, node.DataPathAddress))
+	}
+
+	return strings.Join(args, " ")
+}
+
+// buildJoinCommand builds the command to join node to the swarm of the
+// manager at remoteAddr using the given join token and any extra options
+// (e.g: `--availability drain`)
+func buildJoinCommand(node VMNode, remoteAddr, token string, options ...string) string {
+	args := []string{fmt.Sprintf(joinCommand, node.AdvertiseAddr(), node.AdvertiseAddr(), token)}
+
+	if node.DataPathAddress != "" {
+		args = append(args, fmt.Sprintf(dataPathAddr, node.DataPathAddress))
+	}
+
+	args = append(args, options...)
+
+	args = append(args, fmt.Sprintf(joinAddr, remoteAddr))
+
+	return strings.Join(args, " ")
+}
+
+// Ping switches to the node at nodeAddr and checks that its Docker daemon is
+// responding, returning the daemon's version. This is much cheaper than
+// GetInfo and is suitable as a readiness probe for freshly provisioned
+// nodes. A *ConnectionError is returned if the node cannot be reached or its
+// daemon is not responding.
+func (m *Manager) Ping(nodeAddr string) (string, error) {
+	if err := m.SwitchNode(nodeAddr); err != nil {
+		return "", &ConnectionError{Node: nodeAddr, Err: err}
+	}
+
+	stdout, err := m.runProbeCmd(versionCommand)
+	if err != nil {
+		return "", &ConnectionError{Node: nodeAddr, Err: err}
+	}
+
+	data, err := ioutil.ReadAll(stdout)
+	if err != nil {
+		return "", fmt.Errorf("error reading stdout: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// GetInfoOf returns information about the node at nodeAddr and then switches
+// back to the node that was current before.
+func (m *Manager) GetInfoOf(nodeAddr string) (NodeInfo, error) {
+	var node NodeInfo
+
+	err := m.withNode(nodeAddr, func() error {
+		var err error
+		node, err = m.GetInfo()
+		if err != nil {
+			return fmt.Errorf("error getting node info: %w", err)
+		}
+		return nil
+	})
+
+	return node, err
+}
+
+// GetInfo returns information about the current node
+func (m *Manager) GetInfo() (NodeInfo, error) {
+	var node NodeInfo
+
+	if m.config.InfoCacheTTL > 0 {
+		if cached, ok := m.info.get(m.Switcher().String()); ok {
+			return cached, nil
+		}
+	}
+
+	cmd := infoCommand
+	out, err := m.runProbeCmd(cmd)
+	if err != nil {
+		return NodeInfo{}, fmt.Errorf("error running info command: %w", err)
+	}
+
+	data, err := ioutil.ReadAll(out)
+	if err != nil {
+		return NodeInfo{}, fmt.Errorf("error reading info command output: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &node); err != nil {
+		return NodeInfo{}, fmt.Errorf("error parsing json data: %s", err)
+	}
+
+	m.checkVersion(node)
+
+	if m.config.InfoCacheTTL > 0 {
+		m.info.set(m.Switcher().String(), node, m.config.InfoCacheTTL)
+	}
+
+	return node, nil
+}
+
+// checkVersion warns (once per node) if the node is running a version of
+// Docker older than MinDockerVersion.
+func (m *Manager) checkVersion(node NodeInfo) {
+	if node.ServerVersion == "" || CompareVersions(node.ServerVersion, MinDockerVersion) >= 0 {
+		return
+	}
+
+	if _, warned := m.versionWarned.LoadOrStore(node.Name, true); warned {
+		return
+	}
+
+	log.Warnf(
+		"node %s is running Docker %s which is older than the minimum supported version %s",
+		node.Name, node.ServerVersion, MinDockerVersion,
+	)
+}
+
+// IsLeader returns true if the current node is the leader of the swarm's
+// managers. Unlike GetInfo this inspects the node for its manager status.
+func (m *Manager) IsLeader() (bool, error) {
+	node, err := m.GetInfo()
+	if err != nil {
+		return false, fmt.Errorf("error getting node info: %w", err)
+	}
+	if !node.IsManager() {
+		return false, nil
+	}
+
+	node.ManagerStatus, err = m.getManagerStatus()
+	if err != nil {
+		return false, fmt.Errorf("error getting manager status: %w", err)
+	}
+
+	return node.IsLeader(), nil
+}
+
+// getManagerStatus returns the manager status of the current node which must
+// be a manager node.
+func (m *Manager) getManagerStatus() (NodeManagerStatus, error) {
+	var status NodeManagerStatus
+
+	cmd := selfStatusCommand
+	out, err := m.runCmd(cmd)
+	if err != nil {
+		return NodeManagerStatus{}, fmt.Errorf("error running inspect command: %w", err)
+	}
+
+	data, err := ioutil.ReadAll(out)
+	if err != nil {
+		return NodeManagerStatus{}, fmt.Errorf("error reading inspect command output: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &status); err != nil {
+		return NodeManagerStatus{}, fmt.Errorf("error parsing json data: %s", err)
+	}
+
+	return status, nil
+}
+
+// GetManagers returns a list of manager nodes and their information. If the
+// Switcher implements Cloner the managers are queried concurrently, bounded
+// by the configured Concurrency. A MultiError reports every manager that
+// could not be queried.
+func (m *Manager) GetManagers() ([]NodeInfo, error) {
+	managers, errs, err := m.queryManagers()
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return managers, nil
+}
+
+// queryManagers returns the information of each of the managers of the
+// swarm that could be queried and the errors of those that could not.
+func (m *Manager) queryManagers() ([]NodeInfo, MultiError, error) {
+	node, err := m.GetInfo()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting node info: %w", err)
+	}
+
+	var hosts []string
+	for _, remoteManager := range node.Swarm.RemoteManagers {
+		host, _, err := net.SplitHostPort(remoteManager.Addr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing remote manager address: %w", err)
+		}
+		hosts = append(hosts, host)
+	}
+
+	if m.config.Concurrency > 1 && len(hosts) > 1 {
+		if _, ok := m.Switcher().(Cloner); ok {
+			managers, errs := m.getManagersConcurrently(hosts)
+			return managers, errs, nil
+		}
+	}
+
+	var (
+		managers []NodeInfo
+		errs     MultiError
+	)
+	err = m.preserveNode(func() error {
+		for _, host := range hosts {
+			if err := m.SwitchNode(host); err != nil {
+				errs = append(errs, fmt.Errorf("error switching nodes to %s: %w", host, err))
+				continue
+			}
+			node, err := m.GetInfo()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error getting manager node info from %s: %w", host, err))
+				continue
+			}
+			managers = append(managers, node)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return managers, errs, nil
+}
+
+// getManagersConcurrently gets the information of each of the hosts using a
+// clone of the Manager per host. The order of the hosts is preserved and
+// only the hosts that could be queried are returned.
+func (m *Manager) getManagersConcurrently(hosts []string) ([]NodeInfo, MultiError) {
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, m.config.Concurrency)
+		errs = make([]error, len(hosts))
+	)
+
+	results := make([]NodeInfo, len(hosts))
+
+	for i, host := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, host string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			c, _ := m.clone()
+			if err := c.SwitchNode(host); err != nil {
+				errs[i] = fmt.Errorf("error switching nodes to %s: %w", host, err)
+				return
+			}
+			node, err := c.GetInfo()
+			if err != nil {
+				errs[i] = fmt.Errorf("error getting manager node info from %s: %w", host, err)
+				return
+			}
+			results[i] = node
+		}(i, host)
+	}
+
+	wg.Wait()
+
+	var (
+		managers []NodeInfo
+		merr     MultiError
+	)
+	for i, err := range errs {
+		if err != nil {
+			merr = append(merr, err)
+			continue
+		}
+		managers = append(managers, results[i])
+	}
+
+	return managers, merr
+}
+
+// GetNodes returns all nodes in the cluster
+func (m *Manager) GetNodes() (Nodes, error) {
+	if err := m.ensureManager(); err != nil {
+		return nil, fmt.Errorf("error connecting to manager node: %w", err)
 	}
 
 	cmd := nodesCommand
-	stdout, err := m.runCmd(cmd)
+	stdout, err := m.runCmdStream(cmd)
 	if err != nil {
 		return nil, fmt.Errorf("error running nodes command: %w", err)
 	}
+	defer stdout.Close()
+
+	var nodes Nodes
+
+	if err := decodeJSONLines(stdout, &nodes); err != nil {
+		return nil, fmt.Errorf("error parsing json data: %w", err)
+	}
+
+	return nodes, nil
+}
+
+// StreamNodes calls fn with each node in the cluster as it is decoded from
+// the output of `docker node ls` instead of buffering every node like
+// GetNodes, for very large clusters. Streaming stops at the first error
+// returned by fn which is returned by StreamNodes unless it is ErrStop.
+func (m *Manager) StreamNodes(fn func(NodeStatus) error) error {
+	if err := m.ensureManager(); err != nil {
+		return fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	stdout, err := m.runCmdStream(nodesCommand)
+	if err != nil {
+		return fmt.Errorf("error running nodes command: %w", err)
+	}
+	defer stdout.Close()
+
+	var fnErr error
+	err = scanJSONLines(stdout, func(n int, line []byte) error {
+		var node NodeStatus
+		if err := json.Unmarshal(line, &node); err != nil {
+			return &DecodeError{Line: n, Sample: truncate(string(line), maxDecodeSample), Err: err}
+		}
+		fnErr = fn(node)
+		return fnErr
+	})
+	switch {
+	case errors.Is(fnErr, ErrStop):
+		return nil
+	case fnErr != nil:
+		return fnErr
+	case err != nil:
+		return fmt.Errorf("error parsing json data: %w", err)
+	}
+
+	return nil
+}
+
+// WaitForNodeCount polls the nodes of the cluster until exactly count nodes
+// are ready or ctx is done (e.g: as a barrier while nodes join a swarm
+// asynchronously). Errors getting the nodes are logged and retried.
+func (m *Manager) WaitForNodeCount(ctx context.Context, count int) error {
+	var ready int
+
+	interval := drainPollMin
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			nodes, err := m.GetNodes()
+			if err != nil {
+				log.WithError(err).Warn("error getting nodes (retrying)")
+			} else if ready = nodes.Ready(); ready == count {
+				return nil
+			} else {
+				log.Infof("Waiting for %d ready nodes (%d ready) ...", count, ready)
+			}
+
+			interval = nextInterval(interval)
+			timer.Reset(interval)
+		case <-ctx.Done():
+			return fmt.Errorf("error waiting for %d ready nodes (%d ready): %w", count, ready, ctx.Err())
+		}
+	}
+}
+
+// waitForReady polls the nodes of the cluster until every one of vms is Ready
+// or the WaitForReady timeout expires. It does nothing if WaitForReady is
+// not set. Errors getting the nodes are logged and retried.
+func (m *Manager) waitForReady(vms VMNodes) error {
+	if m.config.WaitForReady == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.WaitForReady)
+	defer cancel()
+
+	var pending []string
+
+	interval := drainPollMin
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			nodes, err := m.GetNodes()
+			if err != nil {
+				log.WithError(err).Warn("error getting nodes (retrying)")
+			} else {
+				pending = nil
+				for _, vm := range vms {
+					node, ok, _ := nodes.FindByHostname(vm.Hostname)
+					if !ok || node.Status != "Ready" {
+						pending = append(pending, vm.Hostname)
+					}
+				}
+				if len(pending) == 0 {
+					return nil
+				}
+				log.Infof("Waiting for %s to be ready ...", strings.Join(pending, ","))
+			}
+
+			interval = nextInterval(interval)
+			timer.Reset(interval)
+		case <-ctx.Done():
+			return fmt.Errorf("error waiting for nodes %s to be ready: %w", strings.Join(pending, ","), ctx.Err())
+		}
+	}
+}
+
+// inspectNodes returns the detailed information of one or more nodes in the
+// cluster given by their ID or hostname. This must be run on a manager node.
+func (m *Manager) inspectNodes(nodes ...string) ([]NodeDetail, error) {
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	cmd := fmt.Sprintf(inspectCommand, strings.Join(nodes, " "))
+	stdout, err := m.runCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error running inspect command: %w", err)
+	}
+
+	var details []NodeDetail
+
+	if err := decodeJSONLines(stdout, &details); err != nil {
+		return nil, fmt.Errorf("error parsing json data: %w", err)
+	}
+
+	return details, nil
+}
+
+// GetLeader returns the detailed information of the current leader of the
+// swarm's managers.
+func (m *Manager) GetLeader() (NodeDetail, error) {
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return NodeDetail{}, fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	for _, node := range nodes {
+		if node.ManagerStatus != "Leader" {
+			continue
+		}
+
+		details, err := m.inspectNodes(node.ID)
+		if err != nil {
+			return NodeDetail{}, fmt.Errorf("error inspecting leader %s: %w", node.Hostname, err)
+		}
+		if len(details) != 1 {
+			return NodeDetail{}, fmt.Errorf("error inspecting leader %s: no details returned", node.Hostname)
+		}
+
+		return details[0], nil
+	}
+
+	return NodeDetail{}, fmt.Errorf("error no leader found")
+}
+
+// waitForLeader waits until the managers report a leader (e.g: after the
+// leader was demoted or removed) or ctx is done. As a leader can only be
+// elected by a majority of the managers this also waits for quorum.
+func (m *Manager) waitForLeader(ctx context.Context) error {
+	interval := drainPollMin
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			nodes, err := m.GetNodes()
+			if err != nil {
+				log.WithError(err).Warn("error getting nodes (retrying)")
+			} else {
+				for _, node := range nodes {
+					if node.ManagerStatus == "Leader" {
+						return nil
+					}
+				}
+				log.Info("Still waiting for a leader to be elected ...")
+			}
+
+			interval = nextInterval(interval)
+			timer.Reset(interval)
+		case <-ctx.Done():
+			return fmt.Errorf("error timed out waiting for a leader to be elected")
+		}
+	}
+}
+
+// onLeader runs fn on the current leader of the swarm's managers, switching
+// to the leader (via the current manager) if necessary. Operations that a
+// non-leader manager may reject should be run with onLeader.
+func (m *Manager) onLeader(fn func() error) error {
+	if err := m.ensureManager(); err != nil {
+		return fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	isLeader, err := m.IsLeader()
+	if err != nil {
+		return err
+	}
+
+	if !isLeader {
+		leader, err := m.GetLeader()
+		if err != nil {
+			return fmt.Errorf("error getting leader: %w", err)
+		}
+
+		host, _, err := net.SplitHostPort(leader.ManagerStatus.Addr)
+		if err != nil {
+			return fmt.Errorf("error parsing leader address: %w", err)
+		}
+
+		if err := m.SwitchNodeVia(host); err != nil {
+			return fmt.Errorf("error switching to leader %s: %w", leader.Hostname(), err)
+		}
+	}
+
+	return fn()
+}
+
+// selectLeader picks a random manager out of the candidates that match the
+// configured LeaderFilter (if any) to initialize a new cluster on.
+func (m *Manager) selectLeader(managers VMNodes) (VMNode, error) {
+	candidates := managers
+
+	if m.config.LeaderFilter != nil {
+		candidates = nil
+		for _, manager := range managers {
+			if m.config.LeaderFilter(manager) {
+				candidates = append(candidates, manager)
+			}
+		}
+		if len(candidates) == 0 {
+			return VMNode{}, fmt.Errorf("error no managers match the leader constraint")
+		}
+	}
+
+	if len(candidates) == 0 {
+		return VMNode{}, fmt.Errorf("error no manager candidates")
+	}
+
+	return candidates[rand.Intn(len(candidates))], nil
+}
+
+// GetNode returns the node in the cluster with the given hostname and
+// whether it was found. See Nodes.FindByHostname for how a hostname that
+// matches more than one node is handled.
+func (m *Manager) GetNode(hostname string) (NodeStatus, bool, error) {
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return NodeStatus{}, false, err
+	}
+
+	return nodes.FindByHostname(hostname)
+}
+
+// CreateSwarm creates a new Docker Swarm cluster given a set of nodes. If
+// any of the managers is already a manager of a swarm cluster whose nodes
+// are all in vms (e.g: a previous CreateSwarm failed part way) the missing
+// nodes are joined to it instead (see UpdateSwarm) so CreateSwarm can be
+// safely re-run.
+func (m *Manager) CreateSwarm(vms VMNodes, force bool) error {
+	return m.createSwarm(vms, force)
+}
+
+// CreateSwarmResult is CreateSwarm but also returns the cluster's ID, leader
+// and members. If workers failed to join with ContinueOnWorkerError the
+// result is returned along with the *WorkerJoinError.
+func (m *Manager) CreateSwarmResult(vms VMNodes, force bool) (CreateResult, error) {
+	err := m.createSwarm(vms, force)
+
+	var joinErr *WorkerJoinError
+	if err != nil && !errors.As(err, &joinErr) {
+		return CreateResult{}, err
+	}
+
+	result, resultErr := m.createResult(vms)
+	if resultErr != nil {
+		return CreateResult{}, fmt.Errorf("error getting created swarm cluster: %w", resultErr)
+	}
+
+	return result, err
+}
+
+// createResult returns the CreateResult of the cluster of the current node
+// with the members that are in vms.
+func (m *Manager) createResult(vms VMNodes) (CreateResult, error) {
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return CreateResult{}, fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	info, err := m.GetInfo()
+	if err != nil {
+		return CreateResult{}, fmt.Errorf("error getting node info: %w", err)
+	}
+
+	result := CreateResult{ClusterID: info.Swarm.ClusterID()}
+	for _, node := range nodes {
+		if len(vms.FilterByHostname(node.Hostname)) == 0 {
+			continue
+		}
+
+		if node.ManagerStatus == "Leader" {
+			result.Leader = node.Hostname
+		}
+		if node.Role() == ManagerRole {
+			result.Managers = append(result.Managers, node.Hostname)
+		} else {
+			result.Workers = append(result.Workers, node.Hostname)
+		}
+	}
+
+	return result, nil
+}
 
-	var nodes []NodeStatus
+// createSwarm implements CreateSwarm
+func (m *Manager) createSwarm(vms VMNodes, force bool) error {
+	if err := checkRoles(vms); err != nil {
+		return fmt.Errorf("error checking node roles: %w", err)
+	}
 
-	if err := jsonlines.Decode(stdout, &nodes); err != nil {
-		return nil, fmt.Errorf("error parsing json data: %s", err)
+	vms, err := m.advertisedNodes(vms)
+	if err != nil {
+		return fmt.Errorf("error checking advertise addresses: %w", err)
 	}
 
-	return nodes, nil
-}
+	vms = selectManagers(vms, m.config.ManagerCount)
 
-// CreateSwarm creates a new Docker Swarm cluster given a set of nodes
-func (m *Manager) CreateSwarm(vms VMNodes, force bool) error {
 	managers := vms.FilterByTag(RoleTag, ManagerRole)
 
 	if force {
 		log.Warnf("skipping manager validation and forcing creation of cluster with %d managers", len(managers))
 	} else {
-		if !(len(managers) == 3 || len(managers) == 5) {
-			return fmt.Errorf("error expected 3 or 5 managers but got %d", len(managers))
+		if err := checkValidManagerCount(len(managers)); err != nil {
+			return err
 		}
 	}
 
 	workers := vms.FilterByTag(RoleTag, WorkerRole)
 
-	// Pick a random manager out of the candidates
-	randomIndex := rand.Intn(len(managers))
-	manager := managers[randomIndex]
+	manager, err := m.selectLeader(managers)
+	if err != nil {
+		return fmt.Errorf("error selecting manager to initialize cluster: %w", err)
+	}
 
 	if err := m.SwitchNode(manager.PublicAddress); err != nil {
 		return fmt.Errorf("error switching to a manager node: %w", err)
@@ -361,82 +2088,201 @@ func (m *Manager) CreateSwarm(vms VMNodes, force bool) error {
 		return fmt.Errorf("error getting node info: %w", err)
 	}
 
-	clusterID := node.Swarm.Cluster.ID
+	clusterID := node.Swarm.ClusterID()
 
+	// Complete the creation of a swarm cluster that already exists (e.g: a
+	// previous CreateSwarm failed part way) rather than creating another
 	if clusterID != "" {
-		return fmt.Errorf("error swarm cluster with id %s already exists", clusterID)
+		if err := m.resumeSwarm(manager, vms, force); err != nil {
+			return err
+		}
+		return m.waitForReady(vms)
+	}
+
+	var otherManagers VMNodes
+	for _, vm := range managers {
+		if vm.PublicAddress != manager.PublicAddress {
+			otherManagers = append(otherManagers, vm)
+		}
+	}
+	existing, ok, err := m.findSwarm(otherManagers)
+	if err != nil {
+		return fmt.Errorf("error checking for an existing swarm cluster: %w", err)
+	}
+	if ok {
+		if err := m.resumeSwarm(existing, vms, force); err != nil {
+			return err
+		}
+		return m.waitForReady(vms)
 	}
 
-	cmd := fmt.Sprintf(initCommand, manager.PrivateAddress, manager.PrivateAddress)
-	if _, err := m.runCmd(cmd); err != nil {
+	if node.Name != manager.Hostname {
+		return &HostnameMismatchError{
+			Addr:     manager.PublicAddress,
+			Expected: manager.Hostname,
+			Actual:   node.Name,
+		}
+	}
+
+	var others VMNodes
+	for _, vm := range append(managers, workers...) {
+		if vm.PublicAddress != manager.PublicAddress {
+			others = append(others, vm)
+		}
+	}
+	if err := m.checkHostnames(others); err != nil {
+		return fmt.Errorf("error checking node hostnames: %w", err)
+	}
+
+	cmd := buildInitCommand(manager)
+	if _, err := m.runMutatingCmd(cmd); err != nil {
 		return fmt.Errorf("error running init command: %w", err)
 	}
+	m.progress(PhaseInit, manager.Hostname, 1, 1)
 
 	// Refresh node and get new Swarm Clsuter ID
 	node, err = m.GetInfo()
 	if err != nil {
 		return fmt.Errorf("error refreshing node info: %w", err)
 	}
-	clusterID = node.Swarm.Cluster.ID
+	clusterID = node.Swarm.ClusterID()
+
+	// The address other nodes join on is the address the leader advertises
+	// which is only known after initializing if it advertises an interface
+	remoteAddr := manager.PrivateAddress
+	if remoteAddr == "" {
+		remoteAddr = node.Swarm.NodeAddr
+	}
 
-	managerToken, err := m.JoinToken(managerToken)
+	managerToken, err := m.joinToken(managerToken)
 	if err != nil {
 		return fmt.Errorf("error getting manager join token: %w", err)
 	}
 
-	workerToken, err := m.JoinToken(workerToken)
+	workerToken, err := m.joinToken(workerToken)
 	if err != nil {
 		return fmt.Errorf("error getting worker join token: %w", err)
 	}
 
-	// Join remaining managers
-	for _, newManager := range managers {
-		// Skip the leader we just created the swarm with
-		if newManager.PublicAddress == manager.PublicAddress {
-			continue
+	if m.config.Preflight {
+		if err := m.checkReachable(others, remoteAddr); err != nil {
+			return fmt.Errorf("error checking connectivity to manager: %w", err)
 		}
+	}
 
-		if err := m.joinSwarm(newManager, manager, managerToken); err != nil {
-			return fmt.Errorf(
-				"error joining manager %s to %s on swarm clsuter %s: %w",
-				newManager.PublicAddress, manager.PublicAddress,
-				clusterID, err,
-			)
-		}
+	// Join remaining managers (skipping the leader we just created the
+	// swarm with) and then workers
+	if err := m.joinNodes(others.FilterByTag(RoleTag, ManagerRole), remoteAddr, managerToken, ManagerRole, PhaseManagerJoined); err != nil {
+		return fmt.Errorf("error joining managers to swarm clsuter %s: %w", clusterID, err)
 	}
 
-	// Join workers
-	for _, worker := range workers {
-		if err := m.joinSwarm(worker, manager, workerToken); err != nil {
-			return fmt.Errorf(
-				"error joining worker %s to %s on swarm clsuter %s: %w",
-				worker.PublicAddress, manager.PublicAddress,
-				clusterID, err,
-			)
-		}
+	vms, workerErr, err := m.joinWorkers(vms, workers, remoteAddr, workerToken)
+	if err != nil {
+		return fmt.Errorf("error joining workers to swarm clsuter %s: %w", clusterID, err)
 	}
 
-	if err := m.SwitchNode(manager.PublicAddress); err != nil {
-		return fmt.Errorf("error switching to manager node: %w", err)
+	if err := m.postJoin(vms); err != nil {
+		return fmt.Errorf("error configuring nodes: %w", err)
 	}
 
-	// Label nodes
-	for _, vm := range vms {
-		if err := m.LabelNode(vm); err != nil {
-			return fmt.Errorf("error labelling node %s: %w", vm, err)
-		}
+	if err := m.waitForReady(vms); err != nil {
+		return err
 	}
 
-	if err := m.SwitchNode(manager.PublicAddress); err != nil {
-		return fmt.Errorf("error switching to manager node: %w", err)
+	if workerErr != nil {
+		return workerErr
 	}
 
 	return nil
 }
 
+// findSwarm returns the first of the managers that is already a manager of a
+// swarm cluster (e.g: from a previous CreateSwarm that failed part way) and
+// whether one was found.
+func (m *Manager) findSwarm(managers VMNodes) (VMNode, bool, error) {
+	var (
+		found VMNode
+		ok    bool
+	)
+
+	err := m.preserveNode(func() error {
+		for _, vm := range managers {
+			if err := m.SwitchNode(vm.PublicAddress); err != nil {
+				return fmt.Errorf("error switching nodes to %s: %w", vm.PublicAddress, err)
+			}
+
+			node, err := m.GetInfo()
+			if err != nil {
+				return fmt.Errorf("error getting node info from %s: %w", vm.PublicAddress, err)
+			}
+
+			if node.IsManager() && node.Swarm.ClusterID() != "" {
+				found, ok = vm, true
+				return nil
+			}
+		}
+		return nil
+	})
+
+	return found, ok, err
+}
+
+// resumeSwarm completes the creation of the swarm cluster that manager is
+// already a manager of by joining the missing nodes in vms. An error is
+// returned if the cluster has any nodes that are not in vms as it was not
+// created from them.
+func (m *Manager) resumeSwarm(manager VMNode, vms VMNodes, force bool) error {
+	if err := m.SwitchNode(manager.PublicAddress); err != nil {
+		return fmt.Errorf("error switching to a manager node: %w", err)
+	}
+
+	node, err := m.GetInfo()
+	if err != nil {
+		return fmt.Errorf("error getting node info: %w", err)
+	}
+	clusterID := node.Swarm.ClusterID()
+
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	var unknown []string
+	for _, node := range nodes {
+		if len(vms.FilterByHostname(node.Hostname)) == 0 {
+			unknown = append(unknown, node.Hostname)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf(
+			"error swarm cluster with id %s already exists with nodes %s not in the Clusterfile",
+			clusterID, strings.Join(unknown, ","),
+		)
+	}
+
+	log.Warnf("swarm cluster with id %s already exists on %s, joining missing nodes", clusterID, manager.Hostname)
+
+	return m.updateSwarm(vms, force)
+}
+
 // UpdateSwarm updates an existing Docker Swarm cluster by adding any
 // missing manager or worker nodes that aren't already part of the cluster
 func (m *Manager) UpdateSwarm(vms VMNodes) error {
+	return m.updateSwarm(vms, false)
+}
+
+// updateSwarm is UpdateSwarm but if force is true does not check the number
+// of managers (see CreateSwarm).
+func (m *Manager) updateSwarm(vms VMNodes, force bool) error {
+	if err := checkRoles(vms); err != nil {
+		return fmt.Errorf("error checking node roles: %w", err)
+	}
+
+	vms, err := m.advertisedNodes(vms)
+	if err != nil {
+		return fmt.Errorf("error checking advertise addresses: %w", err)
+	}
+
 	currentNodes := make(map[string]bool)
 	desiredNodes := make(map[string]bool)
 
@@ -468,17 +2314,43 @@ func (m *Manager) UpdateSwarm(vms VMNodes) error {
 	}
 
 	managers := vms.FilterByTag(RoleTag, ManagerRole)
-	if !(len(managers) == 3 || len(managers) == 5) {
-		return fmt.Errorf("error expected 3 or 5 managers but got %d", len(managers))
+	if !force {
+		if err := checkValidManagerCount(len(managers)); err != nil {
+			return err
+		}
 	}
 
-	// Pick a random manager out of the candidates
-	randomIndex := rand.Intn(len(managers))
-	manager := managers[randomIndex]
-
 	newWorkers := newNodes.FilterByTag(RoleTag, WorkerRole)
 	newManagers := newNodes.FilterByTag(RoleTag, ManagerRole)
 
+	// Pick a random manager out of the candidates that are already part of
+	// the cluster
+	var candidates VMNodes
+	for _, manager := range managers {
+		if currentNodes[manager.Hostname] {
+			candidates = append(candidates, manager)
+		}
+	}
+	var manager VMNode
+	if len(candidates) > 0 {
+		manager = candidates[rand.Intn(len(candidates))]
+	}
+
+	var existingManagers int
+	for _, node := range nodes {
+		if node.Role() == ManagerRole {
+			existingManagers++
+		}
+	}
+	if !force {
+		if err := checkManagerCount(existingManagers + len(newManagers)); err != nil {
+			return fmt.Errorf(
+				"error adding %d managers to %d existing managers: %w",
+				len(newManagers), existingManagers, err,
+			)
+		}
+	}
+
 	if err := m.ensureManager(); err != nil {
 		return fmt.Errorf("error connecting to manager node: %w", err)
 	}
@@ -488,114 +2360,272 @@ func (m *Manager) UpdateSwarm(vms VMNodes) error {
 		return fmt.Errorf("error getting node info: %w", err)
 	}
 
-	clusterID := node.Swarm.Cluster.ID
+	clusterID := node.Swarm.ClusterID()
 
 	if clusterID == "" {
 		return fmt.Errorf("error no swarm cluster found")
 	}
 
-	managerToken, err := m.JoinToken(managerToken)
+	// Join the current manager if no manager was chosen or the chosen
+	// manager advertises an interface
+	remoteAddr := manager.PrivateAddress
+	if remoteAddr == "" {
+		remoteAddr = node.Swarm.NodeAddr
+	}
+
+	managerToken, err := m.joinToken(managerToken)
 	if err != nil {
 		return fmt.Errorf("error getting manager join token: %w", err)
 	}
 
-	workerToken, err := m.JoinToken(workerToken)
+	workerToken, err := m.joinToken(workerToken)
 	if err != nil {
 		return fmt.Errorf("error getting worker join token: %w", err)
 	}
 
-	// Join new managers
-	for _, newManager := range newManagers {
-		if err := m.joinSwarm(newManager, manager, managerToken); err != nil {
-			return fmt.Errorf(
-				"error joining manager %s to %s on swarm clsuter %s: %w",
-				newManager.PublicAddress, manager.PublicAddress,
-				clusterID, err,
-			)
-		}
-		if err := m.LabelNode(newManager); err != nil {
-			return fmt.Errorf("error labelling manager: %w", err)
-		}
+	if err := m.checkHostnames(newNodes); err != nil {
+		return fmt.Errorf("error checking node hostnames: %w", err)
 	}
 
-	// Join new workers
-	for _, newWorker := range newWorkers {
-		if err := m.joinSwarm(newWorker, manager, workerToken); err != nil {
-			return fmt.Errorf(
-				"error joining worker %s to %s on swarm clsuter %s: %w",
-				newWorker.PublicAddress, manager.PublicAddress,
-				clusterID, err,
-			)
-		}
-		if err := m.LabelNode(newWorker); err != nil {
-			return fmt.Errorf("error labelling worker: %w", err)
-		}
+	// Join new managers and then new workers
+	if err := m.joinNodes(newManagers, remoteAddr, managerToken, ManagerRole, PhaseManagerJoined); err != nil {
+		return fmt.Errorf("error joining managers to swarm clsuter %s: %w", clusterID, err)
+	}
+
+	newNodes, workerErr, err := m.joinWorkers(newNodes, newWorkers, remoteAddr, workerToken)
+	if err != nil {
+		return fmt.Errorf("error joining workers to swarm clsuter %s: %w", clusterID, err)
+	}
+
+	if err := m.postJoin(newNodes); err != nil {
+		return fmt.Errorf("error configuring new nodes: %w", err)
 	}
 
 	// Remove old nodes
-	if err := m.DrainNodes(nodesToDrain); err != nil {
+	if _, err := m.DrainNodes(nodesToDrain); err != nil {
 		log.WithError(err).Error("error ddraining old nodes")
 		return fmt.Errorf("error draining old nodes: %w", err)
 	}
 
-	if err := m.SwitchNode(manager.PublicAddress); err != nil {
-		return fmt.Errorf("error switching to manager node: %w", err)
+	if workerErr != nil {
+		return workerErr
 	}
 
 	return nil
 }
 
+// GetTasks returns the tasks of the node given by its ID or hostname
+func (m *Manager) GetTasks(node string) (Tasks, error) {
+	if err := m.ensureManager(); err != nil {
+		return nil, fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	tasks, err := m.getTasks(node)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tasks of %s: %w", node, err)
+	}
+
+	return tasks, nil
+}
+
+// getTasks returns the tasks of a node or of several nodes given by their
+// space separated IDs. This must be run on a manager node.
 func (m *Manager) getTasks(node string) (Tasks, error) {
-	cmd := fmt.Sprintf(tasksCommand, node)
-	stdout, err := m.runCmd(cmd)
+	cmd := fmt.Sprintf(tasksCommand, m.truncOption(node))
+	stdout, err := m.runCmdStream(cmd)
 	if err != nil {
 		return nil, fmt.Errorf("error running tasks command: %w", err)
 	}
+	defer stdout.Close()
 
 	var tasks Tasks
 
-	if err := jsonlines.Decode(stdout, &tasks); err != nil {
-		return nil, fmt.Errorf("error parsing json data: %s", err)
+	if err := decodeJSONLines(stdout, &tasks); err != nil {
+		return nil, fmt.Errorf("error parsing json data: %w", err)
 	}
 
 	return tasks, nil
 }
 
-func (m *Manager) drainNode(node string) error {
+func (m *Manager) drainNode(node string) (DrainResult, error) {
 	startedAt := time.Now()
 
-	cmd := fmt.Sprintf(updateCommand, fmt.Sprintf(setAvailability, availabilityDrain), node)
-	_, err := m.runCmd(cmd)
+	result, tasks, err := m.drainingTasks(node)
 	if err != nil {
-		return fmt.Errorf("error running update command: %w", err)
+		m.publishError(node, err)
+		return result, err
+	}
+
+	if err := m.updateAvailability(node, AvailabilityDrain); err != nil {
+		m.publishError(node, err)
+		return result, err
+	}
+
+	if m.config.DrainForce {
+		for _, service := range result.Services {
+			if _, err := m.runMutatingCmd(fmt.Sprintf(serviceForce, service)); err != nil {
+				log.WithError(err).Warnf("error force updating service %s", service)
+			}
+		}
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
 	defer cancel()
 
-	ticker := time.NewTicker(time.Second * 5)
-	defer ticker.Stop()
+	if err := m.waitForDrain(ctx, &result, tasks, startedAt); err != nil {
+		m.publishError(node, err)
+		return result, err
+	}
+
+	return result, nil
+}
+
+// drainingTasks returns a DrainResult for node with the tasks and services
+// that have yet to be evicted from it along with the node's tasks excluding
+// DrainIgnoreServices.
+func (m *Manager) drainingTasks(node string) (DrainResult, Tasks, error) {
+	result := DrainResult{Node: node}
+
+	tasks, err := m.getTasks(node)
+	if err != nil {
+		return result, nil, fmt.Errorf("error getting tasks: %w", err)
+	}
+	tasks = tasks.WithoutServices(m.config.DrainIgnoreServices)
+	for _, task := range tasks {
+		if task.Terminated() {
+			continue
+		}
+		result.Tasks = append(result.Tasks, task.ID)
+		if service := task.ServiceName(); !HasString(result.Services, service) {
+			result.Services = append(result.Services, service)
+		}
+	}
+
+	return result, tasks, nil
+}
+
+// WaitForDrain blocks until every task on a node (given by its ID or
+// hostname) that is already draining (e.g: drained out-of-band) has shut
+// down or ctx is done. Unlike DrainNode it does not change the node's
+// availability and an error is returned if the node is not set to drain.
+func (m *Manager) WaitForDrain(ctx context.Context, node string) error {
+	if err := m.ensureManager(); err != nil {
+		return fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	details, err := m.inspectNodes(node)
+	if err != nil {
+		return fmt.Errorf("error inspecting node %s: %w", node, err)
+	}
+	if len(details) != 1 {
+		return fmt.Errorf("error inspecting node %s: expected 1 node but got %d", node, len(details))
+	}
+	if availability := details[0].Spec.Availability; availability != string(AvailabilityDrain) {
+		return fmt.Errorf("error node %s is not draining (availability %s)", node, availability)
+	}
+
+	startedAt := time.Now()
+
+	result, tasks, err := m.drainingTasks(node)
+	if err != nil {
+		return fmt.Errorf("error waiting for %s to drain: %w", node, err)
+	}
+	if tasks.AllShutdown() {
+		return nil
+	}
+
+	return m.waitForDrain(ctx, &result, tasks, startedAt)
+}
+
+// waitForDrain polls the tasks of the node of result until they have all
+// shut down, the drain stalls on global services or ctx is done. last are
+// the node's tasks when draining started. result is updated with the time
+// elapsed since startedAt and any tasks left stuck on the node.
+func (m *Manager) waitForDrain(ctx context.Context, result *DrainResult, last Tasks, startedAt time.Time) error {
+	node := result.Node
+
+	interval := drainPollMin
+	remaining := -1
+	failures := 0
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
 			elapsed := time.Since(startedAt)
 
 			tasks, err := m.getTasks(node)
 			if err != nil {
-				log.WithError(err).Warnf("error getting tasks from node %s (retrying)", node)
+				failures++
+				if failures >= m.config.DrainMaxFailures {
+					result.Elapsed = elapsed
+					return fmt.Errorf(
+						"error getting tasks from node %s failed %d consecutive times after %s: %w",
+						node, failures, elapsed, err,
+					)
+				}
+				log.WithError(err).Warnf("error getting tasks from node %s (retrying %d/%d)", node, failures, m.config.DrainMaxFailures)
+				interval = nextInterval(interval)
+				timer.Reset(interval)
 				continue
 			}
+			failures = 0
+			tasks = tasks.WithoutServices(m.config.DrainIgnoreServices)
+			last = tasks
 
 			if tasks.AllShutdown() {
+				if m.config.DrainWaitHealthy && len(result.Services) > 0 {
+					if err := m.waitForServices(ctx, result.Services); err != nil {
+						result.Elapsed = time.Since(startedAt)
+						return fmt.Errorf("error waiting for services evicted from %s: %w", node, err)
+					}
+					elapsed = time.Since(startedAt)
+				}
+
 				log.Infof("Successfully drained %s after %s", node, elapsed)
+				m.publish(Event{Phase: PhaseDrained, Node: node, Percent: 100})
+				result.Elapsed = elapsed
 				return nil
 			}
 
-			log.Infof("Still waiting for %s to drain after %s ...", node, elapsed)
+			active := tasks.Active()
+			stalled := remaining >= 0 && active >= remaining
+			if remaining >= 0 && active < remaining {
+				interval = drainPollMin
+			} else {
+				interval = nextInterval(interval)
+			}
+			remaining = active
+
+			m.publish(Event{Phase: PhaseDraining, Node: node, Percent: drainPercent(len(result.Tasks), active)})
+
+			if stalled {
+				if services, err := m.stuckGlobalServices(tasks); err != nil {
+					log.WithError(err).Warn("error checking for global services")
+				} else if len(services) > 0 {
+					result.Elapsed = elapsed
+					for _, task := range tasks {
+						if !task.Terminated() {
+							result.Stuck = append(result.Stuck, task.Name)
+						}
+					}
+					return &GlobalServiceError{Node: node, Services: services}
+				}
+			}
+
+			log.Infof("Still waiting for %s to drain (%d tasks remaining) after %s ...", node, active, elapsed)
+			timer.Reset(interval)
 		case <-ctx.Done():
 			elapsed := time.Since(startedAt)
 			log.Errorf("timed out waiting for %s to drain after %s", node, elapsed)
+			result.Elapsed = elapsed
+			for _, task := range last {
+				if !task.Terminated() {
+					result.Stuck = append(result.Stuck, task.Name)
+				}
+			}
 			return fmt.Errorf("error timed out waiting for %s to drain after %s", node, elapsed)
 		}
 	}
@@ -603,21 +2633,200 @@ func (m *Manager) drainNode(node string) error {
 	// Unreachable
 }
 
+// GetServices returns all services in the cluster
+func (m *Manager) GetServices() (Services, error) {
+	if err := m.ensureManager(); err != nil {
+		return nil, fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	stdout, err := m.runCmdStream(servicesCommand)
+	if err != nil {
+		return nil, fmt.Errorf("error running services command: %w", err)
+	}
+	defer stdout.Close()
+
+	var services Services
+
+	if err := decodeJSONLines(stdout, &services); err != nil {
+		return nil, fmt.Errorf("error parsing json data: %w", err)
+	}
+
+	return services, nil
+}
+
+// GetNetworks returns the swarm scoped networks of the cluster
+func (m *Manager) GetNetworks() ([]Network, error) {
+	if err := m.ensureManager(); err != nil {
+		return nil, fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	stdout, err := m.runCmdStream(networksCommand)
+	if err != nil {
+		return nil, fmt.Errorf("error running networks command: %w", err)
+	}
+	defer stdout.Close()
+
+	var networks []Network
+
+	if err := decodeJSONLines(stdout, &networks); err != nil {
+		return nil, fmt.Errorf("error parsing json data: %w", err)
+	}
+
+	return networks, nil
+}
+
+// stuckGlobalServices returns the names of the services of the tasks that
+// have not terminated if all of them belong to global services, which are
+// not rescheduled when a node is drained.
+func (m *Manager) stuckGlobalServices(tasks Tasks) ([]string, error) {
+	services, err := m.GetServices()
+	if err != nil {
+		return nil, err
+	}
+
+	modes := make(map[string]string)
+	for _, service := range services {
+		modes[service.Name] = service.Mode
+	}
+
+	var global []string
+	for _, task := range tasks {
+		if task.Terminated() {
+			continue
+		}
+		name := task.ServiceName()
+		if modes[name] != GlobalMode {
+			return nil, nil
+		}
+		if !HasString(global, name) {
+			global = append(global, name)
+		}
+	}
+
+	return global, nil
+}
+
+// getServiceTasks returns the tasks of the given services that should be
+// running
+func (m *Manager) getServiceTasks(services []string) (Tasks, error) {
+	cmd := fmt.Sprintf(serviceTasks, m.truncOption(strings.Join(services, " ")))
+	stdout, err := m.runCmdStream(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error running service tasks command: %w", err)
+	}
+	defer stdout.Close()
+
+	var tasks Tasks
+
+	if err := decodeJSONLines(stdout, &tasks); err != nil {
+		return nil, fmt.Errorf("error parsing json data: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// truncOption prefixes args with the --no-trunc option if NoTrunc is set
+func (m *Manager) truncOption(args string) string {
+	if m.config.NoTrunc {
+		return noTrunc + " " + args
+	}
+	return args
+}
+
+// waitForServices waits until every task of the given services that should
+// be running is running or ctx is done.
+func (m *Manager) waitForServices(ctx context.Context, services []string) error {
+	interval := drainPollMin
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			tasks, err := m.getServiceTasks(services)
+			if err != nil {
+				log.WithError(err).Warnf("error getting tasks of services %s (retrying)", strings.Join(services, ","))
+			} else if tasks.AllRunning() {
+				return nil
+			} else {
+				log.Infof("Still waiting for services %s to be running ...", strings.Join(services, ","))
+			}
+
+			interval = nextInterval(interval)
+			timer.Reset(interval)
+		case <-ctx.Done():
+			return fmt.Errorf("error timed out waiting for services %s to be running", strings.Join(services, ","))
+		}
+	}
+}
+
+// nextInterval doubles the polling interval up to drainPollMax
+func nextInterval(interval time.Duration) time.Duration {
+	interval *= 2
+	if interval > drainPollMax {
+		return drainPollMax
+	}
+	return interval
+}
+
+// DrainNode drains a single node from an existing Docker Swarm cluster and
+// blocks until there are no more tasks running on it. See DrainNodes.
+func (m *Manager) DrainNode(node string) (DrainResult, error) {
+	if err := m.ensureManager(); err != nil {
+		return DrainResult{Node: node}, fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	if err := m.checkQuorum(); err != nil {
+		return DrainResult{Node: node}, fmt.Errorf("error checking quorum: %w", err)
+	}
+
+	if err := m.checkCapacity([]string{node}); err != nil {
+		return DrainResult{Node: node}, fmt.Errorf("error checking capacity: %w", err)
+	}
+
+	result, err := m.drainNode(node)
+	if err != nil {
+		return result, fmt.Errorf("error draining node %s: %w", node, err)
+	}
+
+	return result, nil
+}
+
 // DrainNodes drains one or more nodes from an existing Docker Swarm cluster
-// and blocks until there are no more tasks running on thoese nodes.
-func (m *Manager) DrainNodes(nodes []string) error {
+// and blocks until there are no more tasks running on thoese nodes. Nodes
+// are not drained if a majority of the managers are not reachable. A
+// DrainResult is returned for each node drained (including a node that
+// failed to drain) describing the tasks that were rescheduled.
+func (m *Manager) DrainNodes(nodes []string) ([]DrainResult, error) {
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
 	if err := m.ensureManager(); err != nil {
-		return fmt.Errorf("error connecting to manager node: %w", err)
+		return nil, fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	if err := m.checkQuorum(); err != nil {
+		return nil, fmt.Errorf("error checking quorum: %w", err)
+	}
+
+	if err := m.checkCapacity(nodes); err != nil {
+		return nil, fmt.Errorf("error checking capacity: %w", err)
 	}
 
+	var results []DrainResult
+
 	for _, node := range nodes {
-		if err := m.drainNode(node); err != nil {
+		result, err := m.drainNode(node)
+		results = append(results, result)
+		if err != nil {
 			log.WithError(err).Errorf("error draining node: %s", node)
-			return fmt.Errorf("error draining node %s: %w", node, err)
+			return results, fmt.Errorf("error draining node %s: %w", node, err)
 		}
 	}
 
-	return nil
+	return results, nil
 }
 
 // JoinToken retrieves the current join token for the given type
@@ -0,0 +1,1127 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestNoRunner tests that operations fail with ErrNoRunner as soon as they
+// switch to a node if the Switcher has no Runner.
+func TestNoRunner(t *testing.T) {
+	assert := assert.New(t)
+
+	switcher, _ := swarm.NewNullSwitcher()
+	m, err := swarm.NewManager(switcher)
+	assert.Nil(err)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", Tags: map[string]string{"role": "manager"}},
+	}
+	assert.ErrorIs(m.CreateSwarm(vms, true), swarm.ErrNoRunner)
+	assert.Equal("", m.CurrentNode())
+
+	m, err = swarm.NewManager(nil)
+	assert.Nil(err)
+	assert.ErrorIs(m.CreateSwarm(vms, true), swarm.ErrNoRunner)
+}
+
+// TestGetNodes tests that `Manager.GetNodes()` decodes the streamed output
+// of `docker node ls`.
+func TestGetNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	m, _ := newTestManager(t)
+
+	nodes, err := m.GetNodes()
+	assert.Nil(err)
+	assert.Len(nodes, 2)
+	assert.Equal("dm1", nodes[0].Hostname)
+	assert.Equal("dw1", nodes[1].Hostname)
+}
+
+// TestIsLeader tests that `Manager.IsLeader()` inspects the node for its
+// manager status and that `Manager.GetInfo()` does not.
+func TestIsLeader(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+
+	node, err := m.GetInfo()
+	assert.NoError(err)
+	assert.False(node.IsLeader())
+	assert.Empty(runner.Commands(`^docker node inspect .* self$`))
+
+	isLeader, err := m.IsLeader()
+	assert.NoError(err)
+	assert.True(isLeader)
+	assert.Len(runner.Commands(`^docker node inspect .* self$`), 1)
+
+	runner.On(`^docker node inspect .* self$`, swarmtest.Response{Stdout: `{"Leader": false}`})
+	isLeader, err = m.IsLeader()
+	assert.NoError(err)
+	assert.False(isLeader)
+}
+
+// TestGetNetworks tests that GetNetworks decodes the swarm scoped networks.
+func TestGetNetworks(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker network ls --filter scope=swarm`, swarmtest.Response{
+		Stdout: `{"ID": "n1", "Name": "ingress", "Driver": "overlay", "Scope": "swarm"}
+{"ID": "n2", "Name": "app", "Driver": "overlay", "Scope": "swarm"}
+`,
+	})
+
+	networks, err := m.GetNetworks()
+	assert.Nil(err)
+	assert.Equal([]swarm.Network{
+		{ID: "n1", Name: "ingress", Driver: "overlay", Scope: "swarm"},
+		{ID: "n2", Name: "app", Driver: "overlay", Scope: "swarm"},
+	}, networks)
+}
+
+// TestGetTasksNoTrunc tests that tasks are listed with their full IDs with
+// NoTrunc.
+func TestGetTasksNoTrunc(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t, swarm.WithNoTrunc())
+	runner.On(`^docker node ps`, swarmtest.Response{
+		Stdout: `{"ID": "k3hbvu0xvmyxlk8gn0h0ouqsc", "Name": "web.1", "DesiredState": "Running", "CurrentState": "Running 1 minute ago"}
+`,
+	})
+
+	tasks, err := m.GetTasks("dw1")
+	assert.Nil(err)
+	assert.Len(tasks, 1)
+	assert.Equal("k3hbvu0xvmyxlk8gn0h0ouqsc", tasks[0].ID)
+	assert.Equal([]string{
+		`docker node ps --format "{{ json .}}" --no-trunc dw1`,
+	}, runner.Commands(`^docker node ps`))
+}
+
+// TestGetNodesError tests that a failing `docker node ls` is reported as an
+// error by `Manager.GetNodes()`.
+func TestGetNodesError(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node ls`, swarmtest.Response{Stderr: "error during connect", ExitCode: 1})
+
+	_, err := m.GetNodes()
+	assert.Error(err)
+}
+
+// TestStreamNodes tests that nodes are passed to the callback one at a time
+// and streaming can be stopped early.
+func TestStreamNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+
+	var hostnames []string
+	assert.Nil(m.StreamNodes(func(node swarm.NodeStatus) error {
+		hostnames = append(hostnames, node.Hostname)
+		return nil
+	}))
+	assert.Equal([]string{"dm1", "dw1"}, hostnames)
+
+	hostnames = nil
+	assert.Nil(m.StreamNodes(func(node swarm.NodeStatus) error {
+		hostnames = append(hostnames, node.Hostname)
+		return swarm.ErrStop
+	}))
+	assert.Equal([]string{"dm1"}, hostnames)
+
+	errNode := errors.New("bad node")
+	err := m.StreamNodes(func(node swarm.NodeStatus) error {
+		return errNode
+	})
+	assert.ErrorIs(err, errNode)
+
+	runner.On(`^docker node ls`, swarmtest.Response{Stderr: "error during connect", ExitCode: 1})
+	assert.Error(m.StreamNodes(func(node swarm.NodeStatus) error {
+		return nil
+	}))
+}
+
+// TestCreateSwarmPreflight tests that `Manager.CreateSwarm()` reports nodes
+// that cannot reach the manager before attempting to join them.
+func TestCreateSwarmPreflight(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t, swarm.WithPreflight())
+	runner.OnNode(
+		"10.0.0.1", `^docker info`,
+		swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`},
+		swarmtest.Response{Stdout: testManagerInfo},
+	)
+	runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw1"}`})
+	runner.On(`^docker swarm init`)
+	runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+	runner.OnNode("10.0.0.2", `^nc `, swarmtest.Response{ExitCode: 1})
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+	}
+
+	err := m.CreateSwarm(vms, true)
+
+	var unreachable *swarm.UnreachableError
+	assert.ErrorAs(err, &unreachable)
+	assert.Equal([]string{"dw1"}, unreachable.Nodes)
+	assert.Empty(runner.Commands(`^docker swarm join `))
+}
+
+// TestEnsureManagerErrors tests that the reason each remote manager could
+// not be connected to is reported.
+func TestEnsureManagerErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	runner := swarmtest.NewFakeRunner()
+	runner.On(`^docker info`, swarmtest.Response{
+		Stdout: `{"Name": "dw1", "Swarm": {"LocalNodeState": "active", "RemoteManagers": [{"NodeID": "1", "Addr": "172.16.0.1:2377"}, {"NodeID": "2", "Addr": "172.16.0.2:2377"}]}}`,
+	})
+
+	switcher := swarmtest.NewFakeSwitcher(runner)
+	switcher.FailSwitch("172.16.0.1", errors.New("connection refused"))
+	switcher.FailSwitch("172.16.0.2", errors.New("no route to host"))
+
+	m, err := swarm.NewManager(switcher)
+	assert.Nil(err)
+	assert.Nil(m.SwitchNode("10.0.0.2"))
+
+	_, err = m.GetNodes()
+	assert.Error(err)
+	assert.Contains(err.Error(), "172.16.0.1")
+	assert.Contains(err.Error(), "connection refused")
+	assert.Contains(err.Error(), "172.16.0.2")
+	assert.Contains(err.Error(), "no route to host")
+
+	var errs swarm.MultiError
+	assert.ErrorAs(err, &errs)
+	assert.Len(errs, 2)
+}
+
+// TestEnsureManagerProbeTimeout tests that a remote manager that does not
+// respond is given up on after the ProbeTimeout and the next is tried.
+func TestEnsureManagerProbeTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	runner := swarmtest.NewFakeRunner()
+	runner.On(`^docker info`, swarmtest.Response{
+		Stdout: `{"Name": "dw1", "Swarm": {"LocalNodeState": "active", "RemoteManagers": [{"NodeID": "1", "Addr": "172.16.0.1:2377"}, {"NodeID": "2", "Addr": "172.16.0.2:2377"}]}}`,
+	})
+	runner.OnNode("172.16.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dm2", "Swarm": {"LocalNodeState": "active", "ControlAvailable": true}}`})
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: testNodes})
+
+	switcher := swarmtest.NewFakeSwitcher(runner)
+	switcher.HangSwitch("172.16.0.1")
+
+	m, err := swarm.NewManager(switcher, swarm.WithProbeTimeout(50*time.Millisecond))
+	assert.Nil(err)
+	assert.Nil(m.SwitchNode("10.0.0.2"))
+
+	startedAt := time.Now()
+	nodes, err := m.GetNodes()
+	assert.Nil(err)
+	assert.Len(nodes, 2)
+	assert.Less(int64(time.Since(startedAt)), int64(time.Second))
+	assert.Equal([]string{"10.0.0.2", "172.16.0.1", "172.16.0.2"}, switcher.Switches())
+
+	_, err = swarm.NewManager(switcher, swarm.WithProbeTimeout(0))
+	assert.Error(err)
+}
+
+// TestGetManagersConcurrent tests that the information of every manager is
+// returned in order when managers are queried concurrently and that every
+// failure is reported.
+func TestGetManagersConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	runner := swarmtest.NewFakeRunner()
+	runner.On(`^docker info`, swarmtest.Response{
+		Stdout: `{"Name": "dm1", "Swarm": {"ControlAvailable": true, "RemoteManagers": [{"NodeID": "1", "Addr": "172.16.0.1:2377"}, {"NodeID": "2", "Addr": "172.16.0.2:2377"}, {"NodeID": "3", "Addr": "172.16.0.3:2377"}]}}`,
+	})
+	runner.OnNode("172.16.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dm2", "Swarm": {"ControlAvailable": true}}`})
+	runner.OnNode("172.16.0.3", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dm3", "Swarm": {"ControlAvailable": true}}`})
+	runner.On(`^docker node inspect .* self$`, swarmtest.Response{Stdout: `{"Leader": false}`})
+
+	switcher := swarmtest.NewFakeSwitcher(runner)
+
+	m, err := swarm.NewManager(switcher, swarm.WithConcurrency(2))
+	assert.Nil(err)
+	assert.Nil(m.SwitchNode("10.0.0.1"))
+
+	managers, err := m.GetManagers()
+	assert.Nil(err)
+	assert.Len(managers, 3)
+	assert.Equal("dm1", managers[0].Name)
+	assert.Equal("dm2", managers[1].Name)
+	assert.Equal("dm3", managers[2].Name)
+	assert.ElementsMatch(
+		[]string{"10.0.0.1", "172.16.0.1", "172.16.0.2", "172.16.0.3"},
+		switcher.Switches(),
+	)
+
+	switcher.FailSwitch("172.16.0.1", errors.New("connection refused"))
+	switcher.FailSwitch("172.16.0.3", errors.New("no route to host"))
+
+	_, err = m.GetManagers()
+	var errs swarm.MultiError
+	assert.ErrorAs(err, &errs)
+	assert.Len(errs, 2)
+}
+
+// TestCreateSwarmProgress tests that a progress event is reported for each
+// milestone of creating a swarm.
+func TestCreateSwarmProgress(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []swarm.ProgressEvent
+	m, runner := newTestManager(t, swarm.WithProgress(func(e swarm.ProgressEvent) {
+		events = append(events, e)
+	}))
+	runner.OnNode(
+		"10.0.0.1", `^docker info`,
+		swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`},
+		swarmtest.Response{Stdout: testManagerInfo},
+	)
+	runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw1"}`})
+	runner.On(`^docker swarm init`)
+	runner.On(`^docker swarm join`)
+	runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+	runner.On(`^docker node update`)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+	}
+
+	vms[1].Tags["availability"] = "drain"
+
+	assert.Nil(m.CreateSwarm(vms, true))
+	assert.Equal("10.0.0.1", m.CurrentNode())
+	assert.Equal([]string{"docker node update --availability drain 2"}, runner.Commands(`^docker node update`))
+	assert.Equal([]swarm.ProgressEvent{
+		{Phase: swarm.PhaseInit, Node: "dm1", Index: 1, Total: 1},
+		{Phase: swarm.PhaseWorkerJoined, Node: "dw1", Index: 1, Total: 1},
+		{Phase: swarm.PhaseLabeled, Index: 2, Total: 2},
+	}, events)
+}
+
+// TestCreateSwarmWithoutLabeling tests that nodes are not labeled when
+// creating a swarm with labeling disabled.
+func TestCreateSwarmWithoutLabeling(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []swarm.ProgressEvent
+	m, runner := newTestManager(t, swarm.WithoutLabeling(), swarm.WithProgress(func(e swarm.ProgressEvent) {
+		events = append(events, e)
+	}))
+	runner.OnNode(
+		"10.0.0.1", `^docker info`,
+		swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`},
+		swarmtest.Response{Stdout: testManagerInfo},
+	)
+	runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw1"}`})
+	runner.On(`^docker swarm init`)
+	runner.On(`^docker swarm join`)
+	runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+	runner.On(`^docker node update`)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager", "labels": "zone=a"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker", "labels": "zone=b"}},
+	}
+
+	assert.Nil(m.CreateSwarm(vms, true))
+	assert.Empty(runner.Commands(`^docker node update`))
+	for _, e := range events {
+		assert.NotEqual(swarm.PhaseLabeled, e.Phase)
+	}
+}
+
+// TestCreateSwarmInvalidRole tests that nodes without a valid role are
+// reported before any work is done.
+func TestCreateSwarmInvalidRole(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "wroker"}},
+		{Hostname: "dw2", PublicAddress: "10.0.0.3", PrivateAddress: "172.16.0.3"},
+	}
+
+	calls := len(runner.Calls())
+
+	err := m.CreateSwarm(vms, true)
+	assert.Error(err)
+	assert.Contains(err.Error(), "dw1,dw2")
+	assert.Len(runner.Calls(), calls)
+
+	assert.Error(m.UpdateSwarm(vms))
+	assert.Len(runner.Calls(), calls)
+}
+
+// TestCreateSwarmInvalidManagerCount tests that a swarm is not created with
+// a number of managers other than 3 or 5 unless forced.
+func TestCreateSwarmInvalidManagerCount(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+	}
+
+	calls := len(runner.Calls())
+
+	var countErr *swarm.InvalidManagerCountError
+	assert.ErrorAs(m.CreateSwarm(vms, false), &countErr)
+	assert.Equal(1, countErr.Got)
+	assert.Len(runner.Calls(), calls)
+
+	assert.ErrorIs(m.UpdateSwarm(vms), swarm.ErrInvalidManagerCount)
+}
+
+// TestCreateSwarmAdvertisePublic tests that nodes advertise and join on
+// their public addresses with AdvertisePublicAddress.
+func TestCreateSwarmAdvertisePublic(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t, swarm.WithAdvertisePublicAddress())
+	runner.OnNode(
+		"10.0.0.1", `^docker info`,
+		swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`},
+		swarmtest.Response{Stdout: testManagerInfo},
+	)
+	runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw1"}`})
+	runner.On(`^docker swarm init`)
+	runner.On(`^docker swarm join`)
+	runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+	runner.On(`^docker node update`)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", AdvertiseInterface: "eth1", Tags: map[string]string{"role": "worker"}},
+	}
+
+	assert.Nil(m.CreateSwarm(vms, true))
+	assert.Equal([]string{
+		"docker swarm init --advertise-addr 10.0.0.1 --listen-addr 10.0.0.1",
+	}, runner.Commands(`^docker swarm init`))
+	assert.Equal([]string{
+		"docker swarm join --advertise-addr 10.0.0.2 --listen-addr 10.0.0.2 --token TOKEN 10.0.0.1:2377",
+	}, runner.Commands(`^docker swarm join `))
+
+	vms[1].PublicAddress = "dw1.example.com"
+	assert.Error(m.CreateSwarm(vms, true))
+}
+
+// TestCreateSwarmJoinAvailability tests that nodes join with their
+// availability if their version of Docker supports it.
+func TestCreateSwarmJoinAvailability(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.OnNode(
+		"10.0.0.1", `^docker info`,
+		swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`},
+		swarmtest.Response{Stdout: testManagerInfo},
+	)
+	runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw1", "ServerVersion": "20.10.7"}`})
+	runner.OnNode("10.0.0.3", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw2"}`})
+	runner.On(`^docker swarm init`)
+	runner.On(`^docker swarm join`)
+	runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+	runner.On(`^docker node update`)
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: testNodes + `{"ID": "3", "Hostname": "dw2", "Status": "Ready", "Availability": "Active"}
+`})
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "10.0.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "10.0.0.2", Tags: map[string]string{"role": "worker", "availability": "drain"}},
+		{Hostname: "dw2", PublicAddress: "10.0.0.3", PrivateAddress: "10.0.0.3", Tags: map[string]string{"role": "worker", "availability": "drain"}},
+	}
+
+	assert.Nil(m.CreateSwarm(vms, true))
+	assert.ElementsMatch([]string{
+		"docker swarm join --advertise-addr 10.0.0.2 --listen-addr 10.0.0.2 --token TOKEN --availability drain 10.0.0.1:2377",
+		"docker swarm join --advertise-addr 10.0.0.3 --listen-addr 10.0.0.3 --token TOKEN 10.0.0.1:2377",
+	}, runner.Commands(`^docker swarm join `))
+	assert.NotEmpty(runner.Commands(`^docker node update --availability drain`))
+}
+
+// TestCreateSwarmResult tests that CreateSwarmResult returns the cluster's
+// ID, leader and members.
+func TestCreateSwarmResult(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.OnNode(
+		"10.0.0.1", `^docker info`,
+		swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`},
+		swarmtest.Response{Stdout: testManagerInfo},
+	)
+	runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw1"}`})
+	runner.On(`^docker swarm init`)
+	runner.On(`^docker swarm join`)
+	runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+	runner.On(`^docker node update`)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+	}
+
+	result, err := m.CreateSwarmResult(vms, true)
+	assert.Nil(err)
+	assert.Equal(swarm.CreateResult{
+		ClusterID: "c1",
+		Leader:    "dm1",
+		Managers:  []string{"dm1"},
+		Workers:   []string{"dw1"},
+	}, result)
+}
+
+// TestCreateSwarmWaitForReady tests that CreateSwarm waits for the nodes
+// that joined to be Ready with WaitForReady.
+func TestCreateSwarmWaitForReady(t *testing.T) {
+	assert := assert.New(t)
+
+	newManager := func(nodes string) (*swarm.Manager, *swarmtest.FakeRunner) {
+		m, runner := newTestManager(t, swarm.WithWaitForReady(100*time.Millisecond))
+		runner.OnNode(
+			"10.0.0.1", `^docker info`,
+			swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`},
+			swarmtest.Response{Stdout: testManagerInfo},
+		)
+		runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw1"}`})
+		runner.On(`^docker swarm init`)
+		runner.On(`^docker swarm join`)
+		runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+		runner.On(`^docker node update`)
+		runner.On(`^docker node ls`, swarmtest.Response{Stdout: nodes})
+		return m, runner
+	}
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+	}
+
+	m, _ := newManager(testNodes)
+	assert.Nil(m.CreateSwarm(vms, true))
+
+	m, _ = newManager(`{"ID": "1", "Hostname": "dm1", "Status": "Ready", "ManagerStatus": "Leader"}
+{"ID": "2", "Hostname": "dw1", "Status": "Unknown", "ManagerStatus": ""}
+`)
+	err := m.CreateSwarm(vms, true)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.Contains(err.Error(), "dw1")
+
+	_, err = swarm.NewManager(nil, swarm.WithWaitForReady(0))
+	assert.Error(err)
+}
+
+// TestPing tests that Ping returns the version of the node's Docker daemon
+// or a *swarm.ConnectionError.
+func TestPing(t *testing.T) {
+	assert := assert.New(t)
+
+	runner := swarmtest.NewFakeRunner()
+	runner.OnNode("10.0.0.1", `^docker version`, swarmtest.Response{Stdout: "20.10.12\n"})
+	runner.OnNode("10.0.0.2", `^docker version`, swarmtest.Response{Stderr: "Cannot connect to the Docker daemon", ExitCode: 1})
+
+	switcher := swarmtest.NewFakeSwitcher(runner)
+	switcher.FailSwitch("10.0.0.3", errors.New("connection refused"))
+
+	m, err := swarm.NewManager(switcher)
+	assert.Nil(err)
+
+	version, err := m.Ping("10.0.0.1")
+	assert.Nil(err)
+	assert.Equal("20.10.12", version)
+
+	var connErr *swarm.ConnectionError
+	for _, addr := range []string{"10.0.0.2", "10.0.0.3"} {
+		_, err = m.Ping(addr)
+		assert.ErrorAs(err, &connErr)
+		assert.Equal(addr, connErr.Node)
+	}
+}
+
+// TestCreateSwarmHostnameMismatch tests that a swarm is not created if the
+// hostname of a node does not match the Clusterfile.
+func TestCreateSwarmHostnameMismatch(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.OnNode("10.0.0.1", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`})
+	runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "localhost"}`})
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+	}
+
+	err := m.CreateSwarm(vms, true)
+
+	var mismatch *swarm.HostnameMismatchError
+	assert.ErrorAs(err, &mismatch)
+	assert.Equal("localhost", mismatch.Actual)
+	assert.Empty(runner.Commands(`^docker swarm init`))
+}
+
+// TestDrainNodesEmpty tests that draining no nodes is a no-op that does not
+// connect to a manager.
+func TestDrainNodesEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+
+	results, err := m.DrainNodes(nil)
+	assert.NoError(err)
+	assert.Empty(results)
+	assert.Empty(runner.Calls())
+}
+
+// TestDrainNodesMaxFailures tests that a drain is aborted once getting the
+// node's tasks fails the configured number of consecutive times.
+func TestDrainNodesMaxFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t, swarm.WithDrainMaxFailures(1))
+	runner.On(
+		`^docker node ps`,
+		swarmtest.Response{},
+		swarmtest.Response{Stderr: "error during connect", ExitCode: 1},
+	)
+	runner.On(`^docker node update`)
+
+	results, err := m.DrainNodes([]string{"dw1"})
+	assert.Error(err)
+	assert.Contains(err.Error(), "1 consecutive times")
+	assert.Len(results, 1)
+}
+
+// TestDrainNodesWaitHealthy tests that draining waits for the evicted
+// services to be running on other nodes.
+func TestDrainNodesWaitHealthy(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t, swarm.WithDrainWaitHealthy())
+	runner.On(
+		`^docker node ps`,
+		swarmtest.Response{Stdout: `{"ID": "t1", "Name": "web.1", "CurrentState": "Running 1 hour ago", "DesiredState": "Running"}` + "\n"},
+		swarmtest.Response{Stdout: `{"ID": "t1", "Name": "web.1", "CurrentState": "Shutdown 1 second ago", "DesiredState": "Shutdown"}` + "\n"},
+	)
+	runner.On(`^docker node update`)
+	runner.On(
+		`^docker service ps`,
+		swarmtest.Response{Stdout: `{"ID": "t2", "Name": "web.1", "CurrentState": "Running 1 second ago", "DesiredState": "Running"}` + "\n"},
+	)
+
+	results, err := m.DrainNodes([]string{"dw1"})
+	assert.Nil(err)
+	assert.Equal([]string{"web"}, results[0].Services)
+	assert.Equal(
+		[]string{`docker service ps --filter desired-state=running --format "{{ json . }}" web`},
+		runner.Commands(`^docker service ps`),
+	)
+}
+
+// TestCreateSwarmJoinRollback tests that a node that fails to join is made
+// to leave the swarm so the join can be retried.
+func TestCreateSwarmJoinRollback(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.OnNode(
+		"10.0.0.1", `^docker info`,
+		swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`},
+		swarmtest.Response{Stdout: testManagerInfo},
+	)
+	runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw1", "Swarm": {"LocalNodeState": "inactive"}}`})
+	runner.On(`^docker swarm init`)
+	runner.On(`^docker swarm join `, swarmtest.Response{Stderr: "context deadline exceeded", ExitCode: 1})
+	runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+	runner.On(`^docker swarm leave`)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+	}
+
+	assert.Error(m.CreateSwarm(vms, true))
+
+	var leaves []swarmtest.Call
+	for _, call := range runner.Calls() {
+		if call.Cmd == "docker swarm leave --force" {
+			leaves = append(leaves, call)
+		}
+	}
+	assert.Equal([]swarmtest.Call{{Node: "10.0.0.2", Cmd: "docker swarm leave --force"}}, leaves)
+}
+
+// TestCreateSwarmJoinTimeout tests that a join that does not complete within
+// the JoinTimeout is waited for and the node is not made to leave the swarm if
+// it joined, whether or not the join command reported an error.
+func TestCreateSwarmJoinTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	delay := time.Millisecond * 100
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+	}
+
+	tests := []swarmtest.Response{
+		{Delay: delay},
+		{Stderr: "context deadline exceeded", ExitCode: 1, Delay: delay},
+	}
+
+	for _, join := range tests {
+		m, runner := newTestManager(t, swarm.WithJoinTimeout(time.Millisecond))
+		runner.OnNode(
+			"10.0.0.1", `^docker info`,
+			swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`},
+			swarmtest.Response{Stdout: testManagerInfo},
+		)
+		runner.OnNode(
+			"10.0.0.2", `^docker info`,
+			swarmtest.Response{Stdout: `{"Name": "dw1", "Swarm": {"LocalNodeState": "inactive"}}`},
+			swarmtest.Response{Stdout: `{"Name": "dw1", "Swarm": {"LocalNodeState": "inactive"}}`},
+			swarmtest.Response{Stdout: `{"Name": "dw1", "Swarm": {"LocalNodeState": "active", "RemoteManagers": [{"NodeID": "1", "Addr": "172.16.0.1:2377"}]}}`},
+		)
+		runner.On(`^docker swarm init`)
+		runner.On(`^docker swarm join `, join)
+		runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+		runner.On(`^docker swarm leave`)
+
+		start := time.Now()
+		err := m.CreateSwarm(vms, true)
+		assert.NoError(err)
+		assert.GreaterOrEqual(time.Since(start), delay)
+
+		assert.Empty(runner.Commands(`^docker swarm leave`))
+	}
+}
+
+// TestCreateSwarmAlreadyJoined tests that a node Docker reports is already
+// part of the swarm is treated as joined but not if it is part of another
+// swarm or has a different role than the one it is being joined as.
+func TestCreateSwarmAlreadyJoined(t *testing.T) {
+	assert := assert.New(t)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+	}
+
+	tests := []struct {
+		remoteManager    string
+		controlAvailable bool
+		err              string
+	}{
+		{"172.16.0.1:2377", false, ""},
+		{"192.168.0.1:2377", false, "another swarm"},
+		{"172.16.0.1:2377", true, "as a manager not a worker"},
+	}
+
+	for _, test := range tests {
+		m, runner := newTestManager(t)
+		runner.OnNode(
+			"10.0.0.1", `^docker info`,
+			swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`},
+			swarmtest.Response{Stdout: testManagerInfo},
+		)
+		runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{
+			Stdout: fmt.Sprintf(
+				`{"Name": "dw1", "Swarm": {"LocalNodeState": "active", "ControlAvailable": %t, "RemoteManagers": [{"NodeID": "1", "Addr": %q}]}}`,
+				test.controlAvailable, test.remoteManager,
+			),
+		})
+		runner.On(`^docker swarm init`)
+		runner.On(`^docker swarm join `, swarmtest.Response{
+			Stderr:   `Error response from daemon: This node is already part of a swarm. Use "docker swarm leave" to leave this swarm and join another one.`,
+			ExitCode: 1,
+		})
+		runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+		runner.On(`^docker node update`)
+
+		err := m.CreateSwarm(vms, true)
+		if test.err == "" {
+			assert.Nil(err)
+		} else {
+			assert.Error(err)
+			assert.Contains(err.Error(), test.err)
+		}
+		assert.Empty(runner.Commands(`^docker swarm leave`))
+	}
+}
+
+// TestCreateSwarmContinueOnWorkerError tests that with ContinueOnWorkerError
+// a worker failing to join doesn't stop the rest of the cluster being created
+// and is reported by a *swarm.WorkerJoinError.
+func TestCreateSwarmContinueOnWorkerError(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t, swarm.WithContinueOnWorkerError())
+	runner.OnNode(
+		"10.0.0.1", `^docker info`,
+		swarmtest.Response{Stdout: `{"Name": "dm1", "Swarm": {"LocalNodeState": "inactive"}}`},
+		swarmtest.Response{Stdout: testManagerInfo},
+	)
+	runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw1"}`})
+	runner.OnNode("10.0.0.3", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw2"}`})
+	runner.On(`^docker swarm init`)
+	runner.On(`^docker swarm join `)
+	runner.OnNode("10.0.0.3", `^docker swarm join `, swarmtest.Response{Stderr: "context deadline exceeded", ExitCode: 1})
+	runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+	runner.On(`^docker swarm leave`)
+	runner.On(`^docker node update`)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker", "labels": "zone=b"}},
+		{Hostname: "dw2", PublicAddress: "10.0.0.3", PrivateAddress: "172.16.0.3", Tags: map[string]string{"role": "worker", "labels": "zone=c"}},
+	}
+
+	err := m.CreateSwarm(vms, true)
+
+	var joinErr *swarm.WorkerJoinError
+	if assert.ErrorAs(err, &joinErr) {
+		assert.Len(joinErr.Errors, 1)
+		assert.Contains(joinErr.Errors, "dw2")
+	}
+	assert.Equal([]string{
+		`docker node update --label-add 'zone=b' 2`,
+	}, runner.Commands(`^docker node update`))
+}
+
+// TestGetInfoOf tests that GetInfoOf switches back to the current node.
+func TestGetInfoOf(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw1"}`})
+
+	node, err := m.GetInfoOf("10.0.0.2")
+	assert.Nil(err)
+	assert.Equal("dw1", node.Name)
+	assert.Equal("10.0.0.1", m.CurrentNode())
+	assert.Equal(
+		[]string{"10.0.0.1", "10.0.0.2", "10.0.0.1"},
+		m.Switcher().(*swarmtest.FakeSwitcher).Switches(),
+	)
+}
+
+// TestDrainNodesForce tests that the services with tasks on a node are force
+// updated when draining with force.
+func TestDrainNodesForce(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t, swarm.WithDrainForce())
+	runner.On(
+		`^docker node ps`,
+		swarmtest.Response{Stdout: `{"ID": "t1", "Name": "job.1", "CurrentState": "Running 1 hour ago"}` + "\n"},
+		swarmtest.Response{Stdout: `{"ID": "t1", "Name": "job.1", "CurrentState": "Shutdown 1 second ago"}` + "\n"},
+	)
+	runner.On(`^docker node update`)
+	runner.On(`^docker service update`)
+
+	_, err := m.DrainNodes([]string{"dw1"})
+	assert.Nil(err)
+	assert.Equal([]string{"docker service update --force --detach job"}, runner.Commands(`^docker service update`))
+}
+
+// TestDrainNodesGlobalService tests that a drain that is stuck on tasks of a
+// global service is aborted naming the service.
+func TestDrainNodesGlobalService(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node ps`, swarmtest.Response{Stdout: `{"ID": "t1", "Name": "agent.2", "CurrentState": "Running 1 hour ago"}` + "\n"})
+	runner.On(`^docker node update`)
+	runner.On(`^docker service ls`, swarmtest.Response{Stdout: `{"ID": "s1", "Name": "agent", "Mode": "global"}` + "\n"})
+
+	results, err := m.DrainNodes([]string{"dw1"})
+
+	var globalErr *swarm.GlobalServiceError
+	assert.ErrorAs(err, &globalErr)
+	assert.Equal([]string{"agent"}, globalErr.Services)
+	assert.Equal([]string{"agent.2"}, results[0].Stuck)
+}
+
+// TestEventChannel tests that events are published for nodes joining,
+// drain progress and failures without blocking when the channel is full.
+func TestEventChannel(t *testing.T) {
+	assert := assert.New(t)
+
+	events := make(chan swarm.Event, 10)
+	m, runner := newTestManager(t, swarm.WithEventChannel(events))
+	runner.On(
+		`^docker node ps`,
+		swarmtest.Response{Stdout: `{"ID": "t1", "Name": "web.1", "CurrentState": "Running 1 hour ago"}
+{"ID": "t2", "Name": "web.2", "CurrentState": "Running 1 hour ago"}
+`},
+		swarmtest.Response{Stdout: `{"ID": "t1", "Name": "web.1", "CurrentState": "Shutdown 1 second ago"}
+{"ID": "t2", "Name": "web.2", "CurrentState": "Running 1 hour ago"}
+`},
+		swarmtest.Response{Stdout: `{"ID": "t1", "Name": "web.1", "CurrentState": "Shutdown 1 second ago"}
+{"ID": "t2", "Name": "web.2", "CurrentState": "Shutdown 1 second ago"}
+`},
+	)
+	runner.On(`^docker node update`)
+
+	_, err := m.DrainNode("dw1")
+	assert.Nil(err)
+	close(events)
+
+	var published []swarm.Event
+	for event := range events {
+		published = append(published, event)
+	}
+	assert.Equal([]swarm.Event{
+		{Phase: swarm.PhaseDraining, Node: "dw1", Percent: 50},
+		{Phase: swarm.PhaseDrained, Node: "dw1", Percent: 100},
+	}, published)
+
+	// Events are dropped rather than blocking when the channel is full
+	events = make(chan swarm.Event)
+	m, runner = newTestManager(t, swarm.WithEventChannel(events))
+	runner.On(`^docker node ps`)
+	runner.On(`^docker node update`, swarmtest.Response{Stderr: "node not found", ExitCode: 1})
+
+	_, err = m.DrainNode("dw1")
+	assert.Error(err)
+
+	events = make(chan swarm.Event, 1)
+	m, runner = newTestManager(t, swarm.WithEventChannel(events))
+	runner.On(`^docker node ps`)
+	runner.On(`^docker node update`, swarmtest.Response{Stderr: "node not found", ExitCode: 1})
+
+	_, err = m.DrainNode("dw1")
+	assert.Error(err)
+	if event := <-events; assert.Equal(swarm.PhaseError, event.Phase) {
+		assert.Equal("dw1", event.Node)
+		assert.Error(event.Err)
+	}
+}
+
+// TestDrainNodesIgnoreServices tests that tasks of ignored services don't
+// stop a drain completing.
+func TestDrainNodesIgnoreServices(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t, swarm.WithDrainIgnoreServices("agent"))
+	runner.On(
+		`^docker node ps`,
+		swarmtest.Response{Stdout: `{"ID": "t1", "Name": "agent.2", "CurrentState": "Running 1 hour ago"}
+{"ID": "t2", "Name": "web.1", "CurrentState": "Running 1 hour ago"}
+`},
+		swarmtest.Response{Stdout: `{"ID": "t1", "Name": "agent.2", "CurrentState": "Running 1 hour ago"}
+{"ID": "t2", "Name": "web.1", "CurrentState": "Shutdown 1 second ago"}
+`},
+	)
+	runner.On(`^docker node update`)
+
+	results, err := m.DrainNodes([]string{"dw1"})
+	assert.Nil(err)
+	if assert.Len(results, 1) {
+		assert.Equal([]string{"t2"}, results[0].Tasks)
+		assert.Equal([]string{"web"}, results[0].Services)
+	}
+}
+
+// TestWaitForDrain tests waiting for a node drained out-of-band without
+// changing its availability.
+func TestWaitForDrain(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node inspect --format "{{ json . }}" dw1$`, swarmtest.Response{Stdout: `{"ID": "2", "Spec": {"Availability": "drain"}}`})
+	runner.On(
+		`^docker node ps`,
+		swarmtest.Response{Stdout: `{"ID": "t1", "Name": "web.1", "CurrentState": "Running 1 hour ago"}
+`},
+		swarmtest.Response{Stdout: `{"ID": "t1", "Name": "web.1", "CurrentState": "Shutdown 1 second ago"}
+`},
+	)
+
+	assert.Nil(m.WaitForDrain(context.Background(), "dw1"))
+	assert.Len(runner.Commands(`^docker node ps`), 2)
+
+	// Already drained
+	assert.Nil(m.WaitForDrain(context.Background(), "dw1"))
+	assert.Len(runner.Commands(`^docker node ps`), 3)
+	assert.Empty(runner.Commands(`^docker node update`))
+
+	runner.On(`^docker node ps`, swarmtest.Response{Stdout: `{"ID": "t1", "Name": "web.1", "CurrentState": "Running 1 hour ago"}
+`})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Error(m.WaitForDrain(ctx, "dw1"))
+
+	runner.On(`^docker node inspect --format "{{ json . }}" dw1$`, swarmtest.Response{Stdout: `{"ID": "2", "Spec": {"Availability": "active"}}`})
+	err := m.WaitForDrain(context.Background(), "dw1")
+	assert.Error(err)
+	assert.Contains(err.Error(), "not draining")
+}
+
+// TestWaitForNodeCount tests waiting until the cluster has a number of ready
+// nodes.
+func TestWaitForNodeCount(t *testing.T) {
+	assert := assert.New(t)
+
+	m, _ := newTestManager(t)
+
+	assert.Nil(m.WaitForNodeCount(context.Background(), 2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	err := m.WaitForNodeCount(ctx, 3)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.Contains(err.Error(), "(2 ready)")
+}
+
+// TestClone tests that a cloned Manager is switched to the same node and
+// switches independently of the original Manager.
+func TestClone(t *testing.T) {
+	assert := assert.New(t)
+
+	m, _ := newTestManager(t)
+
+	c, err := m.Clone()
+	assert.Nil(err)
+	assert.Equal("10.0.0.1", c.CurrentNode())
+
+	assert.Nil(c.SwitchNode("10.0.0.2"))
+	assert.Equal("10.0.0.2", c.CurrentNode())
+	assert.Equal("10.0.0.1", m.CurrentNode())
+}
+
+// TestGetNodeAvailability tests getting the availability of a node.
+func TestGetNodeAvailability(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: `{"ID": "1", "Hostname": "dm1", "Status": "Ready", "Availability": "Active", "ManagerStatus": "Leader"}
+{"ID": "2", "Hostname": "dw1", "Status": "Ready", "Availability": "Drain", "ManagerStatus": ""}
+`})
+
+	node, ok, err := m.GetNode("dw1")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(swarm.AvailabilityDrain, node.GetAvailability())
+
+	node, _, _ = m.GetNode("dm1")
+	assert.Equal(swarm.AvailabilityActive, node.GetAvailability())
+}
+
+// TestCreateSwarmResume tests that creating a swarm that already exists
+// with some of the nodes joins the missing nodes, and fails if the swarm has
+// nodes that are not being created.
+func TestCreateSwarmResume(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: `{"ID": "1", "Hostname": "dm1", "Status": "Ready", "ManagerStatus": "Leader"}
+`})
+	runner.OnNode("10.0.0.2", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw1", "Swarm": {"LocalNodeState": "inactive"}}`})
+	runner.On(`^docker swarm init`)
+	runner.On(`^docker swarm join`)
+	runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+
+	vms := swarm.VMNodes{
+		{Hostname: "dm1", PublicAddress: "10.0.0.1", PrivateAddress: "172.16.0.1", Tags: map[string]string{"role": "manager"}},
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+	}
+
+	assert.Nil(m.CreateSwarm(vms, true))
+	assert.Empty(runner.Commands(`^docker swarm init`))
+	assert.Equal([]string{
+		"docker swarm join --advertise-addr 172.16.0.2 --listen-addr 172.16.0.2 --token TOKEN 172.16.0.1:2377",
+	}, runner.Commands(`^docker swarm join `))
+
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: testNodes + `{"ID": "3", "Hostname": "other", "Status": "Ready"}
+`})
+	err := m.CreateSwarm(vms, true)
+	assert.Error(err)
+	assert.Contains(err.Error(), "other")
+}
+
+// TestFailureLogLevel tests that failed commands are logged at the
+// configured level and that failed probes are only logged at debug level.
+func TestFailureLogLevel(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node update`, swarmtest.Response{Stderr: "node not found", ExitCode: 1})
+	runner.OnNode("10.0.0.2", `^docker version`, swarmtest.Response{Stderr: "daemon not running", ExitCode: 1})
+
+	hook.Reset()
+	assert.Error(m.SetAvailability("dw9", swarm.AvailabilityDrain))
+	assert.Equal(log.ErrorLevel, hook.LastEntry().Level)
+
+	hook.Reset()
+	_, err := m.Ping("10.0.0.2")
+	assert.Error(err)
+	for _, entry := range hook.AllEntries() {
+		assert.NotEqual(log.ErrorLevel, entry.Level)
+	}
+
+	m, runner = newTestManager(t, swarm.WithFailureLogLevel(log.DebugLevel))
+	runner.On(`^docker node update`, swarmtest.Response{Stderr: "node not found", ExitCode: 1})
+
+	hook.Reset()
+	assert.Error(m.SetAvailability("dw9", swarm.AvailabilityDrain))
+	for _, entry := range hook.AllEntries() {
+		assert.NotEqual(log.ErrorLevel, entry.Level)
+	}
+
+	_, err = swarm.NewManager(swarmtest.NewFakeSwitcher(runner), swarm.WithFailureLogLevel(log.FatalLevel))
+	assert.Error(err)
+}
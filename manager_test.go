@@ -0,0 +1,480 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aucloud/go-runcmd"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// testResponse is the response of a testSwitcher's Runner to a command
+type testResponse struct {
+	stdout string
+	stderr string
+	code   int
+}
+
+// testExitError is returned by a command whose testResponse has a non-zero
+// code.
+type testExitError struct {
+	code int
+}
+
+func (e *testExitError) Error() string   { return fmt.Sprintf("exit status %d", e.code) }
+func (e *testExitError) ExitStatus() int { return e.code }
+
+// testSwitcher is a Switcher for internal tests whose Runner responds to
+// every command with respond and records the commands run.
+type testSwitcher struct {
+	sync.Mutex
+
+	node    string
+	respond func(cmd string) testResponse
+	calls   []string
+}
+
+func newTestSwitcher(respond func(cmd string) testResponse) *testSwitcher {
+	return &testSwitcher{respond: respond}
+}
+
+func (s *testSwitcher) String() string {
+	s.Lock()
+	defer s.Unlock()
+	return "test://" + s.node
+}
+
+func (s *testSwitcher) Switch(ctx context.Context, nodeAddr string) error {
+	s.Lock()
+	defer s.Unlock()
+	s.node = nodeAddr
+	return nil
+}
+
+func (s *testSwitcher) SwitchVia(ctx context.Context, nodeAddr string) error {
+	return s.Switch(ctx, nodeAddr)
+}
+
+func (s *testSwitcher) Runner() runcmd.Runner { return s }
+
+func (s *testSwitcher) Command(cmd string) (runcmd.CmdWorker, error) {
+	return &testWorker{switcher: s, cmd: cmd}, nil
+}
+
+// commands returns the commands run so far that start with prefix
+func (s *testSwitcher) commands(prefix string) []string {
+	s.Lock()
+	defer s.Unlock()
+
+	var cmds []string
+	for _, cmd := range s.calls {
+		if strings.HasPrefix(cmd, prefix) {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+type testWorker struct {
+	switcher *testSwitcher
+	cmd      string
+
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (w *testWorker) Run() ([]string, error) {
+	stdout := &strings.Builder{}
+	w.SetStdout(stdout)
+	err := w.Wait()
+	return strings.Split(strings.TrimSpace(stdout.String()), "\n"), err
+}
+
+func (w *testWorker) Start() error { return nil }
+
+func (w *testWorker) Wait() error {
+	w.switcher.Lock()
+	w.switcher.calls = append(w.switcher.calls, w.cmd)
+	w.switcher.Unlock()
+
+	res := w.switcher.respond(w.cmd)
+	if w.stdout != nil {
+		io.WriteString(w.stdout, res.stdout)
+	}
+	if w.stderr != nil {
+		io.WriteString(w.stderr, res.stderr)
+	}
+	if res.code != 0 {
+		return &testExitError{code: res.code}
+	}
+	return nil
+}
+
+func (w *testWorker) StdinPipe() (io.WriteCloser, error) { return nil, errors.New("not supported") }
+func (w *testWorker) StdoutPipe() (io.Reader, error)     { return nil, errors.New("not supported") }
+func (w *testWorker) StderrPipe() (io.Reader, error)     { return nil, errors.New("not supported") }
+func (w *testWorker) SetStdout(buffer io.Writer)         { w.stdout = buffer }
+func (w *testWorker) SetStderr(buffer io.Writer)         { w.stderr = buffer }
+func (w *testWorker) GetCommandLine() string             { return w.cmd }
+
+// TestExecCmdWarnings tests that a command that exits zero but writes to
+// stderr succeeds with each non-empty line of stderr as a warning and that
+// only a non-zero exit status is an error.
+func TestExecCmdWarnings(t *testing.T) {
+	assert := assert.New(t)
+
+	switcher := newTestSwitcher(func(cmd string) testResponse {
+		if cmd == "docker version" {
+			return testResponse{stdout: "20.10.12\n", stderr: "WARNING: API is accessible\n\n  WARNING: bridge-nf-call-iptables is disabled  \n"}
+		}
+		return testResponse{stderr: "Error: no such command", code: 1}
+	})
+
+	m, err := NewManager(switcher)
+	assert.NoError(err)
+
+	res, err := m.execCmd(log.DebugLevel, "docker version")
+	assert.NoError(err)
+	assert.Equal("20.10.12\n", res.Stdout.String())
+	assert.Equal([]string{"WARNING: API is accessible", "WARNING: bridge-nf-call-iptables is disabled"}, res.Warnings)
+
+	_, err = m.execCmd(log.DebugLevel, "docker foo")
+	assert.Error(err)
+}
+
+// TestGetInfoCache tests that GetInfo is cached for the InfoCacheTTL and the
+// cache is invalidated by switching nodes and by commands that change the
+// swarm.
+func TestGetInfoCache(t *testing.T) {
+	assert := assert.New(t)
+
+	switcher := newTestSwitcher(func(cmd string) testResponse {
+		return testResponse{stdout: `{"Name": "dm1"}`}
+	})
+	infos := func() int {
+		return len(switcher.commands("docker info"))
+	}
+
+	m, err := NewManager(switcher, WithInfoCacheTTL(time.Minute))
+	assert.NoError(err)
+
+	_, err = m.GetInfo()
+	assert.NoError(err)
+	_, err = m.GetInfo()
+	assert.NoError(err)
+	assert.Equal(1, infos())
+
+	assert.NoError(m.SwitchNode("10.0.0.2"))
+	_, err = m.GetInfo()
+	assert.NoError(err)
+	assert.Equal(2, infos())
+
+	_, err = m.runMutatingCmd("docker node update --label-add zone=a 1")
+	assert.NoError(err)
+	_, err = m.GetInfo()
+	assert.NoError(err)
+	assert.Equal(3, infos())
+
+	m, err = NewManager(switcher, WithInfoCacheTTL(time.Millisecond))
+	assert.NoError(err)
+
+	_, err = m.GetInfo()
+	assert.NoError(err)
+	time.Sleep(time.Millisecond * 5)
+	_, err = m.GetInfo()
+	assert.NoError(err)
+	assert.Equal(5, infos())
+}
+
+// TestNextInterval tests that the polling interval doubles up to
+// drainPollMax.
+func TestNextInterval(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		interval time.Duration
+		expected time.Duration
+	}{
+		{drainPollMin, drainPollMin * 2},
+		{time.Second * 8, time.Second * 16},
+		{time.Second * 15, drainPollMax},
+		{time.Second * 20, drainPollMax},
+		{drainPollMax, drainPollMax},
+	}
+
+	for _, test := range tests {
+		assert.Equal(test.expected, nextInterval(test.interval), "interval %s", test.interval)
+	}
+}
+
+// TestDrainPollReset tests that the drain polling interval is reset when the
+// number of tasks left on the node decreases between polls.
+func TestDrainPollReset(t *testing.T) {
+	assert := assert.New(t)
+
+	task := func(id, state string) string {
+		return fmt.Sprintf(`{"ID": "%s", "Name": "web.%s", "CurrentState": "%s 1 second ago", "DesiredState": "Running"}`+"\n", id, id, state)
+	}
+
+	// Polls are 2s and 4s apart with both tasks running and then 2s apart
+	// again once a task has shutdown (rather than 8s if the interval kept
+	// growing).
+	start := time.Now()
+	switcher := newTestSwitcher(func(cmd string) testResponse {
+		switch {
+		case strings.HasPrefix(cmd, "docker info"):
+			return testResponse{stdout: `{"Name": "dm1", "Swarm": {"NodeID": "1", "LocalNodeState": "active", "ControlAvailable": true, "RemoteManagers": [{"NodeID": "1", "Addr": "172.16.0.1:2377"}]}}`}
+		case strings.HasPrefix(cmd, "docker node inspect"):
+			return testResponse{stdout: `{"Leader": true, "Reachability": "reachable", "Addr": "172.16.0.1:2377"}`}
+		case strings.HasPrefix(cmd, "docker node ls"):
+			return testResponse{stdout: `{"ID": "1", "Hostname": "dm1", "Status": "Ready", "Availability": "Active", "ManagerStatus": "Leader"}` + "\n"}
+		case strings.HasPrefix(cmd, "docker node ps"):
+			switch elapsed := time.Since(start); {
+			case elapsed < time.Second*5:
+				return testResponse{stdout: task("1", "Running") + task("2", "Running")}
+			case elapsed < time.Second*7:
+				return testResponse{stdout: task("1", "Shutdown") + task("2", "Running")}
+			default:
+				return testResponse{stdout: task("1", "Shutdown") + task("2", "Shutdown")}
+			}
+		}
+		return testResponse{}
+	})
+
+	m, err := NewManager(switcher)
+	assert.NoError(err)
+
+	_, err = m.DrainNodes([]string{"dw1"})
+	assert.NoError(err)
+	assert.Less(int64(time.Since(start)), int64(time.Second*10))
+}
+
+// TestSelectLeader tests that `selectLeader()` only picks managers that
+// match the configured leader constraint.
+func TestSelectLeader(t *testing.T) {
+	assert := assert.New(t)
+
+	managers := VMNodes{
+		{Hostname: "dm1", Tags: map[string]string{"role": "manager", "labels": "zone=a"}},
+		{Hostname: "dm2", Tags: map[string]string{"role": "manager", "labels": "zone=b"}},
+		{Hostname: "dm3", Tags: map[string]string{"role": "manager", "labels": "zone=a"}},
+	}
+
+	m, err := NewManager(nil, WithPreferredLeaderLabel("zone", "b"))
+	assert.Nil(err)
+
+	for i := 0; i < 10; i++ {
+		leader, err := m.selectLeader(managers)
+		assert.Nil(err)
+		assert.Equal("dm2", leader.Hostname)
+	}
+
+	m, err = NewManager(nil, WithPreferredLeaderLabel("zone", "c"))
+	assert.Nil(err)
+
+	_, err = m.selectLeader(managers)
+	assert.Error(err)
+}
+
+// TestBuildCommands tests building the init and join commands with and
+// without a data path address.
+func TestBuildCommands(t *testing.T) {
+	assert := assert.New(t)
+
+	node := VMNode{Hostname: "dm1", PrivateAddress: "172.16.0.1"}
+	assert.Equal(
+		"docker swarm init --advertise-addr 172.16.0.1 --listen-addr 172.16.0.1",
+		buildInitCommand(node),
+	)
+	assert.Equal(
+		"docker swarm join --advertise-addr 172.16.0.1 --listen-addr 172.16.0.1 --token T 172.16.0.2:2377",
+		buildJoinCommand(node, "172.16.0.2", "T"),
+	)
+
+	node.DataPathAddress = "192.168.0.1"
+	assert.Equal(
+		"docker swarm init --advertise-addr 172.16.0.1 --listen-addr 172.16.0.1 --data-path-addr 192.168.0.1",
+		buildInitCommand(node),
+	)
+	assert.Equal(
+		"docker swarm join --advertise-addr 172.16.0.1 --listen-addr 172.16.0.1 --token T --data-path-addr 192.168.0.1 172.16.0.2:2377",
+		buildJoinCommand(node, "172.16.0.2", "T"),
+	)
+
+	node = VMNode{Hostname: "dm1", AdvertiseInterface: "eth1"}
+	assert.Equal(
+		"docker swarm init --advertise-addr eth1 --listen-addr eth1",
+		buildInitCommand(node),
+	)
+	assert.Equal(
+		"docker swarm join --advertise-addr eth1 --listen-addr eth1 --token T 172.16.0.2:2377",
+		buildJoinCommand(node, "172.16.0.2", "T"),
+	)
+}
+
+// TestBuildSwarmUpdateCommand tests that only non-zero settings are passed
+// to `docker swarm update`.
+func TestBuildSwarmUpdateCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("", buildSwarmUpdateCommand(SwarmConfig{}))
+	assert.Equal(
+		"docker swarm update --task-history-limit 2",
+		buildSwarmUpdateCommand(SwarmConfig{TaskHistoryLimit: 2}),
+	)
+	assert.Equal(
+		"docker swarm update --task-history-limit 1 --dispatcher-heartbeat 10s --cert-expiry 720h0m0s",
+		buildSwarmUpdateCommand(SwarmConfig{
+			TaskHistoryLimit:    1,
+			DispatcherHeartbeat: 10 * time.Second,
+			CertExpiry:          30 * 24 * time.Hour,
+		}),
+	)
+}
+
+// TestPrepareCmd tests that configured environment variables are applied to
+// commands in a stable order and quoted.
+func TestPrepareCmd(t *testing.T) {
+	assert := assert.New(t)
+
+	m, err := NewManager(nil)
+	assert.Nil(err)
+	assert.Equal("docker info", m.prepareCmd("docker info"))
+
+	m, err = NewManager(nil, WithEnv(map[string]string{
+		"DOCKER_HOST":      "tcp://10.0.0.1:2376",
+		"DOCKER_CERT_PATH": "/etc/docker/it's",
+	}))
+	assert.Nil(err)
+	assert.Equal(
+		`env DOCKER_CERT_PATH='/etc/docker/it'\''s' DOCKER_HOST='tcp://10.0.0.1:2376' docker info`,
+		m.prepareCmd("docker info"),
+	)
+
+	_, err = NewManager(nil, WithEnv(map[string]string{"A=B": "C"}))
+	assert.Error(err)
+
+	m, err = NewManager(nil, WithCommandPrefix("sudo -n"), WithDockerPath("/opt/bin/docker"))
+	assert.Nil(err)
+	assert.Equal("sudo -n /opt/bin/docker info", m.prepareCmd("docker info"))
+	assert.Equal("sudo -n nc -z -w 5 172.16.0.1 2377", m.prepareCmd("nc -z -w 5 172.16.0.1 2377"))
+
+	m, err = NewManager(nil, WithCommandPrefix("sudo"), WithEnv(map[string]string{"DOCKER_HOST": "tcp://10.0.0.1:2376"}))
+	assert.Nil(err)
+	assert.Equal(
+		`sudo env DOCKER_HOST='tcp://10.0.0.1:2376' docker info`,
+		m.prepareCmd("docker info"),
+	)
+}
+
+// TestQuorum tests that a majority of managers must be reachable.
+func TestQuorum(t *testing.T) {
+	assert := assert.New(t)
+
+	nodes := Nodes{
+		{Hostname: "dm1", ManagerStatus: "Leader"},
+		{Hostname: "dm2", ManagerStatus: "Reachable"},
+		{Hostname: "dm3", ManagerStatus: "Unreachable"},
+		{Hostname: "dw1"},
+	}
+	assert.Nil(quorum(nodes))
+
+	nodes[1].ManagerStatus = "Unreachable"
+
+	var quorumErr *QuorumError
+	assert.ErrorAs(quorum(nodes), &quorumErr)
+	assert.Equal(1, quorumErr.Reachable)
+	assert.Equal(3, quorumErr.Total)
+}
+
+// TestCheckManagerCount tests that the number of managers must be odd.
+func TestCheckManagerCount(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, n := range []int{1, 3, 5, 7, 9} {
+		assert.Nil(checkManagerCount(n))
+	}
+	for _, n := range []int{0, 2, 4, 6} {
+		assert.Error(checkManagerCount(n))
+	}
+}
+
+// TestSelectManagers tests that managers are picked from the candidates in
+// order of hostname and the others are tagged as workers.
+func TestSelectManagers(t *testing.T) {
+	assert := assert.New(t)
+
+	var vms VMNodes
+	for _, hostname := range []string{"dm5", "dm2", "dm4", "dm1", "dm3"} {
+		vms = append(vms, VMNode{Hostname: hostname, Tags: map[string]string{"role": "manager"}})
+	}
+	vms = append(vms, VMNode{Hostname: "dw1", Tags: map[string]string{"role": "worker"}})
+
+	selected := selectManagers(vms, 3)
+	assert.Len(selected, 6)
+
+	var managers []string
+	for _, vm := range selected.FilterByTag(RoleTag, ManagerRole) {
+		managers = append(managers, vm.Hostname)
+	}
+	assert.Equal([]string{"dm2", "dm1", "dm3"}, managers)
+	assert.Len(selected.FilterByTag(RoleTag, WorkerRole), 3)
+
+	// The given nodes are not modified
+	assert.Len(vms.FilterByTag(RoleTag, ManagerRole), 5)
+
+	assert.Equal(vms, selectManagers(vms, 5))
+	assert.Equal(vms, selectManagers(vms, 0))
+
+	for _, n := range []int{3, 5} {
+		_, err := NewManager(nil, WithManagerCount(n))
+		assert.Nil(err)
+	}
+	for _, n := range []int{-1, 1, 4, 7} {
+		_, err := NewManager(nil, WithManagerCount(n))
+		assert.Error(err)
+	}
+}
+
+// TestScaleDownOrder tests the order workers are removed in when scaling
+// down.
+func TestScaleDownOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	workers := Nodes{
+		{ID: "1", Hostname: "dw1", Status: "Ready"},
+		{ID: "2", Hostname: "dw2", Status: "Ready"},
+		{ID: "3", Hostname: "dw3", Status: "Down"},
+		{ID: "4", Hostname: "old", Status: "Ready"},
+	}
+	pool := VMNodes{{Hostname: "dw1"}, {Hostname: "dw2"}, {Hostname: "dw3"}}
+
+	assert.Equal([]string{"dw3", "old", "dw2", "dw1"}, scaleDownOrder(workers, pool))
+}
@@ -0,0 +1,128 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// Phase identifies a milestone of a long running operation
+type Phase string
+
+const (
+	// PhaseInit is reported once the swarm has been initialized
+	PhaseInit Phase = "init"
+
+	// PhaseManagerJoined is reported as each manager joins the swarm
+	PhaseManagerJoined Phase = "manager-joined"
+
+	// PhaseWorkerJoined is reported as each worker joins the swarm
+	PhaseWorkerJoined Phase = "worker-joined"
+
+	// PhaseLabeled is reported once all new nodes have been labeled
+	PhaseLabeled Phase = "labeled"
+
+	// PhaseDraining is published as tasks are evicted from a draining node
+	PhaseDraining Phase = "draining"
+
+	// PhaseDrained is published once a node has drained
+	PhaseDrained Phase = "drained"
+
+	// PhaseError is published when an operation on a node fails
+	PhaseError Phase = "error"
+)
+
+// ProgressEvent is passed to the progress callback configured with
+// WithProgress at each milestone of an operation. Index is 1-based and
+// counts the nodes of the phase completed so far out of Total.
+type ProgressEvent struct {
+	Phase Phase
+	Node  string
+	Index int
+	Total int
+}
+
+// Event is published to the channel configured with WithEventChannel at
+// each step of an operation: the milestones reported as a ProgressEvent as
+// well as the progress of draining nodes and failures.
+type Event struct {
+	Phase Phase
+	Node  string
+
+	// Index and Total are as for a ProgressEvent
+	Index int
+	Total int
+
+	// Percent is the percentage of a draining node's tasks that have been
+	// evicted for PhaseDraining and PhaseDrained
+	Percent int
+
+	// Err is the error for PhaseError
+	Err error
+}
+
+// progress reports a milestone to the configured progress callback and
+// event channel if any
+func (m *Manager) progress(phase Phase, node string, index, total int) {
+	m.publish(Event{Phase: phase, Node: node, Index: index, Total: total})
+
+	if m.config.Progress == nil {
+		return
+	}
+
+	m.config.Progress(ProgressEvent{
+		Phase: phase,
+		Node:  node,
+		Index: index,
+		Total: total,
+	})
+}
+
+// publish sends an event to the configured event channel if any without
+// blocking. The event is dropped if the channel is full.
+func (m *Manager) publish(event Event) {
+	if m.config.Events == nil {
+		return
+	}
+
+	select {
+	case m.config.Events <- event:
+	default:
+		log.Debugf("dropping %s event for %s: event channel is full", event.Phase, event.Node)
+	}
+}
+
+// publishError publishes a PhaseError event for a failed operation on node
+func (m *Manager) publishError(node string, err error) {
+	m.publish(Event{Phase: PhaseError, Node: node, Err: err})
+}
+
+// drainPercent returns the percentage of the initial tasks of a draining
+// node that have been evicted given the number still active
+func drainPercent(initial, active int) int {
+	if initial == 0 || active <= 0 {
+		return 100
+	}
+	if active >= initial {
+		return 0
+	}
+	return (initial - active) * 100 / initial
+}
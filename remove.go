@@ -0,0 +1,274 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RemoveState is the state a node was left in by RemoveNodes
+type RemoveState string
+
+const (
+	// NodeUntouched is a node that was not changed
+	NodeUntouched RemoveState = "untouched"
+
+	// NodeRemoved is a node that was removed from the cluster
+	NodeRemoved RemoveState = "removed"
+
+	// NodeReactivated is a node that was drained (and demoted) but was
+	// restored to its previous availability (and role) after a failure
+	NodeReactivated RemoveState = "reactivated"
+
+	// NodeDrained is a node that was drained but could not be restored
+	// after a failure
+	NodeDrained RemoveState = "drained"
+)
+
+// removal tracks the progress of removing a single node
+type removal struct {
+	node    NodeStatus
+	manager bool
+	drained bool
+	demoted bool
+	removed bool
+}
+
+// RemoveNodes drains and removes the nodes with the given hostnames from the
+// cluster. All nodes are drained before any are removed and workers are
+// removed before managers. Managers are demoted and removed one at a time,
+// unreachable managers first and the leader last, and after each the
+// remaining managers must have (or elect) a leader before the next is
+// removed. Nodes are not removed if a majority of the managers are not
+// already reachable, if the remaining nodes do not have the capacity for
+// their tasks (see WithDrainCapacityCheck) or if the cluster would be left
+// with an even number of managers. If any step fails the nodes that were drained but not removed are
+// restored and a *RemoveError reports the state of every node.
+func (m *Manager) RemoveNodes(hostnames []string) error {
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	var workers, managers []*removal
+	for _, hostname := range hostnames {
+		node, ok, err := nodes.FindByHostname(hostname)
+		if err != nil {
+			return fmt.Errorf("error finding node %s: %w", hostname, err)
+		}
+		if !ok {
+			return fmt.Errorf("error node %s not found", hostname)
+		}
+
+		r := &removal{node: node, manager: node.ManagerStatus != ""}
+		if r.manager {
+			managers = append(managers, r)
+		} else {
+			workers = append(workers, r)
+		}
+	}
+
+	if err := m.checkQuorum(); err != nil {
+		return fmt.Errorf("error checking quorum: %w", err)
+	}
+
+	if err := m.checkCapacity(hostnames); err != nil {
+		return fmt.Errorf("error checking capacity: %w", err)
+	}
+
+	var total int
+	for _, node := range nodes {
+		if node.ManagerStatus != "" {
+			total++
+		}
+	}
+	if len(managers) > 0 && len(managers) >= total {
+		return fmt.Errorf("error removing %d managers would leave the cluster without a manager", len(managers))
+	}
+	if len(managers) > 0 {
+		if err := checkManagerCount(total - len(managers)); err != nil {
+			return fmt.Errorf("error removing %d managers: %w", len(managers), err)
+		}
+	}
+
+	// Removing unreachable managers first can only improve quorum and
+	// removing the leader last avoids forcing more than one election.
+	sort.SliceStable(managers, func(i, j int) bool {
+		return removalOrder(managers[i].node) < removalOrder(managers[j].node)
+	})
+
+	removals := append(workers, managers...)
+
+	states := make(map[string]RemoveState)
+	for _, r := range removals {
+		states[r.node.Hostname] = NodeUntouched
+	}
+
+	fail := func(err error) error {
+		m.restoreNodes(removals, states)
+		return &RemoveError{States: states, Err: err}
+	}
+
+	for _, r := range removals {
+		// The node may have been set to drain even if draining times out
+		r.drained = true
+		if _, err := m.drainNode(r.node.ID); err != nil {
+			return fail(fmt.Errorf("error draining node %s: %w", r.node.Hostname, err))
+		}
+	}
+
+	for _, r := range removals {
+		if r.manager {
+			if err := m.ensureManager(); err != nil {
+				return fail(fmt.Errorf("error connecting to manager node: %w", err))
+			}
+			if _, err := m.runMutatingCmd(fmt.Sprintf(demoteCommand, r.node.ID)); err != nil {
+				return fail(fmt.Errorf("error demoting node %s: %w", r.node.Hostname, err))
+			}
+			r.demoted = true
+		}
+
+		// The current node may have been the manager just demoted
+		if err := m.ensureManager(); err != nil {
+			return fail(fmt.Errorf("error connecting to manager node: %w", err))
+		}
+		if _, err := m.runMutatingCmd(fmt.Sprintf(removeCommand, r.node.ID)); err != nil {
+			return fail(fmt.Errorf("error removing node %s: %w", r.node.Hostname, err))
+		}
+		r.removed = true
+		states[r.node.Hostname] = NodeRemoved
+
+		log.Infof("Successfully removed %s", r.node.Hostname)
+
+		if r.manager {
+			ctx, cancel := context.WithTimeout(context.Background(), leaderTimeout)
+			err := m.waitForLeader(ctx)
+			cancel()
+			if err != nil {
+				return fail(fmt.Errorf("error waiting for a leader after removing %s: %w", r.node.Hostname, err))
+			}
+		}
+	}
+
+	return nil
+}
+
+// removalOrder ranks a manager by when it should be removed: unreachable
+// managers first, then reachable managers and the leader last.
+func removalOrder(node NodeStatus) int {
+	switch node.ManagerStatus {
+	case "Leader":
+		return 2
+	case "Reachable":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// restoreNodes restores the role and availability of nodes that were drained
+// but not removed and records the state each was left in.
+func (m *Manager) restoreNodes(removals []*removal, states map[string]RemoveState) {
+	for _, r := range removals {
+		if !r.drained || r.removed {
+			continue
+		}
+
+		states[r.node.Hostname] = NodeDrained
+
+		if err := m.ensureManager(); err != nil {
+			log.WithError(err).Errorf("error connecting to manager to restore %s", r.node.Hostname)
+			continue
+		}
+
+		if r.demoted {
+			if _, err := m.runMutatingCmd(fmt.Sprintf(promoteCommand, r.node.ID)); err != nil {
+				log.WithError(err).Errorf("error promoting %s", r.node.Hostname)
+				continue
+			}
+		}
+
+		availability, err := ParseAvailability(r.node.Availability)
+		if err != nil || availability == AvailabilityDrain {
+			availability = AvailabilityActive
+		}
+
+		if err := m.updateAvailability(r.node.ID, availability); err != nil {
+			log.WithError(err).Errorf("error restoring availability of %s", r.node.Hostname)
+			continue
+		}
+
+		states[r.node.Hostname] = NodeReactivated
+	}
+}
+
+// ListDownNodes returns the nodes in the cluster that are Down, such as
+// nodes that have crashed or been replaced.
+func (m *Manager) ListDownNodes() ([]NodeStatus, error) {
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return nil, fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	var down []NodeStatus
+	for _, node := range nodes {
+		if strings.EqualFold(node.Status, "Down") {
+			down = append(down, node)
+		}
+	}
+
+	return down, nil
+}
+
+// PruneDownNodes removes all Down worker nodes from the cluster and returns
+// the hostnames of the nodes removed. Down managers are not removed as they
+// must be demoted first. Nodes are not removed if a majority of the managers
+// are not reachable.
+func (m *Manager) PruneDownNodes() ([]string, error) {
+	down, err := m.ListDownNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.checkQuorum(); err != nil {
+		return nil, fmt.Errorf("error checking quorum: %w", err)
+	}
+
+	var removed []string
+	for _, node := range down {
+		if node.Role() == ManagerRole {
+			log.Warnf("not removing down manager %s (%s): demote it first", node.Hostname, node.ID)
+			continue
+		}
+
+		if _, err := m.runMutatingCmd(fmt.Sprintf(removeCommand, node.ID)); err != nil {
+			return removed, fmt.Errorf("error removing node %s: %w", node.Hostname, err)
+		}
+		removed = append(removed, node.Hostname)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,214 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestRemoveNodes tests that a worker is drained and removed and that the
+// last manager of a cluster cannot be removed.
+func TestRemoveNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node ps`)
+	runner.On(`^docker node update`)
+	runner.On(`^docker node rm`)
+
+	assert.Error(m.RemoveNodes([]string{"dm1"}))
+	assert.Empty(runner.Commands(`^docker node (update|rm)`))
+
+	assert.Nil(m.RemoveNodes([]string{"dw1"}))
+	assert.Equal([]string{
+		"docker node update --availability drain 2",
+		"docker node rm --force 2",
+	}, runner.Commands(`^docker node (update|rm)`))
+}
+
+// TestRemoveManagers tests that managers are removed one at a time with the
+// unreachable managers first and the leader last, and that removals leaving
+// an even number of managers are refused.
+func TestRemoveManagers(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: `{"ID": "1", "Hostname": "dm1", "Status": "Ready", "Availability": "Active", "ManagerStatus": "Leader"}
+{"ID": "2", "Hostname": "dm2", "Status": "Ready", "Availability": "Active", "ManagerStatus": "Reachable"}
+{"ID": "3", "Hostname": "dm3", "Status": "Down", "Availability": "Active", "ManagerStatus": "Unreachable"}
+{"ID": "4", "Hostname": "dm4", "Status": "Ready", "Availability": "Active", "ManagerStatus": "Reachable"}
+{"ID": "5", "Hostname": "dm5", "Status": "Ready", "Availability": "Active", "ManagerStatus": "Reachable"}
+`})
+	runner.On(`^docker node ps`)
+	runner.On(`^docker node (update|demote|rm)`)
+
+	assert.Error(m.RemoveNodes([]string{"dm2"}))
+	assert.Empty(runner.Commands(`^docker node (update|demote|rm)`))
+
+	assert.Nil(m.RemoveNodes([]string{"dm1", "dm3"}))
+	assert.Equal([]string{
+		"docker node demote 3",
+		"docker node rm --force 3",
+		"docker node demote 1",
+		"docker node rm --force 1",
+	}, runner.Commands(`^docker node (demote|rm)`))
+}
+
+// TestRemoveManagersWaitForLeader tests that the next manager is not removed
+// until the remaining managers have elected a leader.
+func TestRemoveManagersWaitForLeader(t *testing.T) {
+	assert := assert.New(t)
+
+	nodes := `{"ID": "2", "Hostname": "dm2", "Status": "Ready", "Availability": "Active", "ManagerStatus": "Reachable"}
+{"ID": "3", "Hostname": "dm3", "Status": "Ready", "Availability": "Active", "ManagerStatus": "Reachable"}
+`
+
+	m, runner := newTestManager(t)
+	runner.On(
+		`^docker node ls`,
+		swarmtest.Response{Stdout: testNodes + nodes},
+		swarmtest.Response{Stdout: nodes},
+		swarmtest.Response{Stdout: testNodes + nodes},
+	)
+	runner.On(`^docker node ps`)
+	runner.On(`^docker node (update|demote|rm)`)
+
+	assert.Nil(m.RemoveNodes([]string{"dm2", "dm3"}))
+	assert.Len(runner.Commands(`^docker node ls`), 4)
+	assert.Equal([]string{
+		"docker node demote 2",
+		"docker node rm --force 2",
+		"docker node demote 3",
+		"docker node rm --force 3",
+	}, runner.Commands(`^docker node (demote|rm)`))
+}
+
+// TestRemoveNodesRollback tests that a node that was drained but could not
+// be removed is made active again and its state reported.
+func TestRemoveNodesRollback(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node ps`)
+	runner.On(`^docker node update`)
+	runner.On(`^docker node rm`, swarmtest.Response{Stderr: "rpc error", ExitCode: 1})
+
+	err := m.RemoveNodes([]string{"dw1"})
+
+	var removeErr *swarm.RemoveError
+	assert.ErrorAs(err, &removeErr)
+	assert.Equal(map[string]swarm.RemoveState{"dw1": swarm.NodeReactivated}, removeErr.States)
+	assert.Equal([]string{
+		"docker node update --availability drain 2",
+		"docker node update --availability active 2",
+	}, runner.Commands(`^docker node update`))
+}
+
+// TestPruneDownNodes tests that only Down workers are removed.
+func TestPruneDownNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: `{"ID": "1", "Hostname": "dm1", "Status": "Ready", "ManagerStatus": "Leader"}
+{"ID": "2", "Hostname": "dm2", "Status": "Down", "ManagerStatus": "Unreachable"}
+{"ID": "3", "Hostname": "dw1", "Status": "Down", "ManagerStatus": ""}
+{"ID": "4", "Hostname": "dw2", "Status": "Ready", "ManagerStatus": ""}
+`})
+	runner.On(`^docker node rm`)
+
+	down, err := m.ListDownNodes()
+	assert.Nil(err)
+	assert.Len(down, 2)
+
+	removed, err := m.PruneDownNodes()
+	assert.Nil(err)
+	assert.Equal([]string{"dw1"}, removed)
+	assert.Equal([]string{"docker node rm --force 3"}, runner.Commands(`^docker node rm`))
+}
+
+// TestPruneDownNodesQuorum tests that no nodes are removed if a majority of
+// the managers cannot be reached, unless the quorum check is skipped.
+func TestPruneDownNodesQuorum(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, skip := range []bool{false, true} {
+		var options []swarm.Option
+		if skip {
+			options = append(options, swarm.WithSkipQuorumCheck())
+		}
+
+		m, runner := newTestManager(t, options...)
+		runner.OnNode("10.0.0.1", `^docker info`, swarmtest.Response{
+			Stdout: `{"Name": "dm1", "Swarm": {"NodeID": "1", "LocalNodeState": "active", "ControlAvailable": true, "RemoteManagers": [{"NodeID": "1", "Addr": "172.16.0.1:2377"}, {"NodeID": "2", "Addr": "172.16.0.2:2377"}, {"NodeID": "3", "Addr": "172.16.0.3:2377"}]}}`,
+		})
+		runner.OnNode("172.16.0.2", `^docker info`, swarmtest.Response{Stderr: "Cannot connect to the Docker daemon", ExitCode: 1})
+		runner.OnNode("172.16.0.3", `^docker info`, swarmtest.Response{Stderr: "Cannot connect to the Docker daemon", ExitCode: 1})
+		runner.On(`^docker node ls`, swarmtest.Response{Stdout: testNodes + `{"ID": "3", "Hostname": "dw2", "Status": "Down", "ManagerStatus": ""}
+`})
+		runner.On(`^docker node rm`)
+
+		removed, err := m.PruneDownNodes()
+		if skip {
+			assert.Nil(err)
+			assert.Equal([]string{"dw2"}, removed)
+		} else {
+			var quorumErr *swarm.QuorumError
+			if assert.ErrorAs(err, &quorumErr) {
+				assert.Equal(1, quorumErr.Reachable)
+				assert.Equal(3, quorumErr.Total)
+			}
+			assert.Empty(runner.Commands(`^docker node rm`))
+		}
+	}
+}
+
+// TestRemoveNodesCapacity tests that nothing is drained or removed, either
+// directly or when scaling down, if the remaining nodes do not have the
+// capacity for the tasks on the nodes being removed.
+func TestRemoveNodesCapacity(t *testing.T) {
+	assert := assert.New(t)
+
+	removes := []func(m *swarm.Manager) error{
+		func(m *swarm.Manager) error { return m.RemoveNodes([]string{"dw1"}) },
+		func(m *swarm.Manager) error { return m.Scale(nil, 0) },
+	}
+
+	for _, remove := range removes {
+		m, runner := newTestManager(t, swarm.WithDrainCapacityCheck())
+		runner.On(`^docker node inspect --format "{{ json . }}" 1 2$`, swarmtest.Response{Stdout: `{"ID": "1", "Spec": {"Availability": "active"}, "Description": {"Hostname": "dm1", "Resources": {"NanoCPUs": 1000000000, "MemoryBytes": 4294967296}}, "Status": {"State": "ready"}}
+{"ID": "2", "Spec": {"Availability": "active"}, "Description": {"Hostname": "dw1", "Resources": {"NanoCPUs": 4000000000, "MemoryBytes": 8589934592}}, "Status": {"State": "ready"}}
+`})
+		runner.On(`^docker node ps`, swarmtest.Response{Stdout: `{"ID": "t1", "Name": "web.1", "Node": "dw1", "CurrentState": "Running 1 hour ago"}
+`})
+		runner.On(`^docker inspect --type task`, swarmtest.Response{Stdout: `{"ID": "t1", "NodeID": "2", "Spec": {"Resources": {"Reservations": {"NanoCPUs": 2000000000}}}}
+`})
+		runner.On(`^docker node (update|rm)`)
+
+		var capacityErr *swarm.CapacityError
+		assert.ErrorAs(remove(m), &capacityErr)
+		assert.Empty(runner.Commands(`^docker node (update|rm)`))
+	}
+}
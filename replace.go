@@ -0,0 +1,125 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ReplaceManager replaces the manager oldNode (e.g: a failed VM) with the
+// new VM newNode. The old manager is drained if it is still reachable,
+// demoted and removed (forcibly if it is down) before the new node is joined
+// as a manager so the cluster keeps the same odd number of managers. Nothing
+// is changed unless a majority of the managers other than the old manager
+// are reachable so the cluster keeps quorum throughout.
+func (m *Manager) ReplaceManager(oldNode, newNode VMNode) error {
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	old, ok, err := nodes.FindByHostname(oldNode.Hostname)
+	if err != nil {
+		return fmt.Errorf("error finding node %s: %w", oldNode.Hostname, err)
+	}
+	if !ok {
+		return fmt.Errorf("error node %s not found", oldNode.Hostname)
+	}
+	if old.Role() != ManagerRole {
+		return fmt.Errorf("error node %s is not a manager", oldNode.Hostname)
+	}
+
+	if _, ok, _ := nodes.FindByHostname(newNode.Hostname); ok {
+		return fmt.Errorf("error node %s is already part of the cluster", newNode.Hostname)
+	}
+
+	var (
+		total  int
+		others Nodes
+	)
+	for _, node := range nodes {
+		if node.Role() == ManagerRole {
+			total++
+		}
+		if node.ID != old.ID {
+			others = append(others, node)
+		}
+	}
+
+	if err := checkManagerCount(total); err != nil {
+		return fmt.Errorf("error checking manager count: %w", err)
+	}
+
+	if err := m.checkQuorum(); err != nil {
+		return fmt.Errorf("error checking quorum: %w", err)
+	}
+
+	// The remaining managers must have quorum on their own while the old
+	// manager is removed and the new manager joins
+	if !m.config.SkipQuorumCheck {
+		if err := quorum(others); err != nil {
+			return fmt.Errorf("error checking quorum without %s: %w", old.Hostname, err)
+		}
+	}
+
+	if old.ManagerStatus == "Leader" || old.ManagerStatus == "Reachable" {
+		if err := m.checkCapacity([]string{old.ID}); err != nil {
+			return fmt.Errorf("error checking capacity: %w", err)
+		}
+		if _, err := m.drainNode(old.ID); err != nil {
+			return fmt.Errorf("error draining node %s: %w", old.Hostname, err)
+		}
+	} else {
+		log.Warnf("%s is unreachable and will be removed without draining", old.Hostname)
+	}
+
+	if err := m.ensureManager(); err != nil {
+		return fmt.Errorf("error connecting to manager node: %w", err)
+	}
+	if _, err := m.runMutatingCmd(fmt.Sprintf(demoteCommand, old.ID)); err != nil {
+		return fmt.Errorf("error demoting node %s: %w", old.Hostname, err)
+	}
+
+	// The current node may have been the manager just demoted
+	if err := m.ensureManager(); err != nil {
+		return fmt.Errorf("error connecting to manager node: %w", err)
+	}
+	if _, err := m.runMutatingCmd(fmt.Sprintf(removeCommand, old.ID)); err != nil {
+		return fmt.Errorf("error removing node %s: %w", old.Hostname, err)
+	}
+	log.Infof("Successfully removed %s", old.Hostname)
+
+	ctx, cancel := context.WithTimeout(context.Background(), leaderTimeout)
+	defer cancel()
+	if err := m.waitForLeader(ctx); err != nil {
+		return fmt.Errorf("error waiting for a leader after removing %s: %w", old.Hostname, err)
+	}
+
+	if err := m.addNodes(VMNodes{newNode}, managerToken, PhaseManagerJoined); err != nil {
+		return fmt.Errorf("error joining manager %s: %w", newNode.Hostname, err)
+	}
+	log.Infof("Successfully replaced %s with %s", old.Hostname, newNode.Hostname)
+
+	return nil
+}
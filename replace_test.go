@@ -0,0 +1,69 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestReplaceManager tests that an unreachable manager is removed without
+// draining and the replacement is joined as a manager, and that a manager
+// is not replaced if the remaining managers would not have quorum.
+func TestReplaceManager(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: `{"ID": "1", "Hostname": "dm1", "Status": "Ready", "ManagerStatus": "Leader"}
+{"ID": "2", "Hostname": "dm2", "Status": "Ready", "ManagerStatus": "Reachable"}
+{"ID": "3", "Hostname": "dm3", "Status": "Down", "ManagerStatus": "Unreachable"}
+`})
+	runner.OnNode("10.0.0.4", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dm4", "Swarm": {"LocalNodeState": "inactive"}}`})
+	runner.On(`^docker node (demote|rm)`)
+	runner.On(`^docker swarm join`)
+	runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+
+	oldNode := swarm.VMNode{Hostname: "dm3", PublicAddress: "10.0.0.3", PrivateAddress: "172.16.0.3"}
+	newNode := swarm.VMNode{Hostname: "dm4", PublicAddress: "10.0.0.4", PrivateAddress: "172.16.0.4"}
+
+	assert.Nil(m.ReplaceManager(oldNode, newNode))
+	assert.Empty(runner.Commands(`^docker node (ps|update)`))
+	assert.Equal([]string{
+		"docker node demote 3",
+		"docker node rm --force 3",
+	}, runner.Commands(`^docker node (demote|rm)`))
+	assert.Equal([]string{
+		"docker swarm join --advertise-addr 172.16.0.4 --listen-addr 172.16.0.4 --token TOKEN 172.16.0.1:2377",
+	}, runner.Commands(`^docker swarm join `))
+	assert.Equal("10.0.0.1", m.CurrentNode())
+
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: `{"ID": "1", "Hostname": "dm1", "Status": "Ready", "ManagerStatus": "Leader"}
+{"ID": "2", "Hostname": "dm2", "Status": "Down", "ManagerStatus": "Unreachable"}
+{"ID": "3", "Hostname": "dm3", "Status": "Ready", "ManagerStatus": "Reachable"}
+`})
+
+	var quorumErr *swarm.QuorumError
+	assert.ErrorAs(m.ReplaceManager(oldNode, newNode), &quorumErr)
+}
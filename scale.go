@@ -0,0 +1,114 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Scale adjusts the number of workers in the cluster to desiredWorkers.
+// Workers in vms (nodes with the "worker" role) that are not part of the
+// cluster are candidates to join, in order, when scaling up. A
+// *ShortfallError is returned without joining any nodes if there are not
+// enough candidates. When scaling down the excess workers are drained and
+// removed (see RemoveNodes) preferring workers that are Down, then workers
+// not in vms and then the last workers in vms. The capacity of the remaining
+// nodes is checked for all of the excess workers before any are drained.
+// Managers are never changed.
+func (m *Manager) Scale(vms VMNodes, desiredWorkers int) error {
+	if desiredWorkers < 0 {
+		return fmt.Errorf("error invalid number of workers %d", desiredWorkers)
+	}
+
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	var workers Nodes
+	for _, node := range nodes {
+		if node.Role() == WorkerRole {
+			workers = append(workers, node)
+		}
+	}
+
+	pool := vms.FilterByTag(RoleTag, WorkerRole)
+
+	switch {
+	case len(workers) < desiredWorkers:
+		var candidates VMNodes
+		for _, vm := range pool {
+			if _, ok, _ := nodes.FindByHostname(vm.Hostname); !ok {
+				candidates = append(candidates, vm)
+			}
+		}
+
+		n := desiredWorkers - len(workers)
+		if len(candidates) < n {
+			return &ShortfallError{Desired: desiredWorkers, Current: len(workers), Available: len(candidates)}
+		}
+
+		log.Infof("Scaling up from %d to %d workers", len(workers), desiredWorkers)
+		if err := m.addNodes(candidates[:n], workerToken, PhaseWorkerJoined); err != nil {
+			return fmt.Errorf("error scaling up workers: %w", err)
+		}
+	case len(workers) > desiredWorkers:
+		excess := scaleDownOrder(workers, pool)[:len(workers)-desiredWorkers]
+
+		log.Infof("Scaling down from %d to %d workers removing %s", len(workers), desiredWorkers, strings.Join(excess, ","))
+		if err := m.RemoveNodes(excess); err != nil {
+			return fmt.Errorf("error scaling down workers: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// scaleDownOrder returns the hostnames of workers in the order they are
+// removed when scaling down: workers that are Down, then workers not in
+// pool and then workers in pool from last to first.
+func scaleDownOrder(workers Nodes, pool VMNodes) []string {
+	index := make(map[string]int)
+	for i, vm := range pool {
+		index[vm.Hostname] = i
+	}
+
+	var down, unknown, known []string
+	for _, node := range workers {
+		if strings.EqualFold(node.Status, "Down") {
+			down = append(down, node.Hostname)
+		} else if _, ok := index[node.Hostname]; !ok {
+			unknown = append(unknown, node.Hostname)
+		} else {
+			known = append(known, node.Hostname)
+		}
+	}
+
+	sort.SliceStable(known, func(i, j int) bool {
+		return index[known[i]] > index[known[j]]
+	})
+
+	return append(append(down, unknown...), known...)
+}
@@ -0,0 +1,85 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestScaleUp tests that candidate workers are joined in order when scaling
+// up and that nothing is joined if there are not enough candidates.
+func TestScaleUp(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t, swarm.WithoutLabeling())
+	runner.OnNode("10.0.0.3", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw2", "Swarm": {"LocalNodeState": "inactive"}}`})
+	runner.On(`^docker swarm join`)
+	runner.On(`^docker swarm join-token`, swarmtest.Response{Stdout: "TOKEN\n"})
+
+	vms := swarm.VMNodes{
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+		{Hostname: "dw2", PublicAddress: "10.0.0.3", PrivateAddress: "172.16.0.3", Tags: map[string]string{"role": "worker"}},
+		{Hostname: "dw3", PublicAddress: "10.0.0.4", PrivateAddress: "172.16.0.4", Tags: map[string]string{"role": "worker"}},
+	}
+
+	var shortfall *swarm.ShortfallError
+	assert.ErrorAs(m.Scale(vms, 4), &shortfall)
+	assert.Equal(swarm.ShortfallError{Desired: 4, Current: 1, Available: 2}, *shortfall)
+	assert.Empty(runner.Commands(`^docker swarm join `))
+
+	assert.Nil(m.Scale(vms, 1))
+	assert.Empty(runner.Commands(`^docker swarm join `))
+
+	assert.Nil(m.Scale(vms, 2))
+	assert.Equal([]string{
+		"docker swarm join --advertise-addr 172.16.0.3 --listen-addr 172.16.0.3 --token TOKEN 172.16.0.1:2377",
+	}, runner.Commands(`^docker swarm join `))
+}
+
+// TestScaleUpTokenProvider tests that nodes join with the token from the
+// configured TokenProvider instead of fetching it from a manager.
+func TestScaleUpTokenProvider(t *testing.T) {
+	assert := assert.New(t)
+
+	provider := swarm.TokenProviderFunc(func(tokenType string) (string, error) {
+		return "SECRET-" + tokenType, nil
+	})
+
+	m, runner := newTestManager(t, swarm.WithoutLabeling(), swarm.WithTokenProvider(provider))
+	runner.OnNode("10.0.0.3", `^docker info`, swarmtest.Response{Stdout: `{"Name": "dw2", "Swarm": {"LocalNodeState": "inactive"}}`})
+	runner.On(`^docker swarm join`)
+
+	vms := swarm.VMNodes{
+		{Hostname: "dw1", PublicAddress: "10.0.0.2", PrivateAddress: "172.16.0.2", Tags: map[string]string{"role": "worker"}},
+		{Hostname: "dw2", PublicAddress: "10.0.0.3", PrivateAddress: "172.16.0.3", Tags: map[string]string{"role": "worker"}},
+	}
+
+	assert.Nil(m.Scale(vms, 2))
+	assert.Empty(runner.Commands(`^docker swarm join-token`))
+	assert.Equal([]string{
+		"docker swarm join --advertise-addr 172.16.0.3 --listen-addr 172.16.0.3 --token SECRET-worker 172.16.0.1:2377",
+	}, runner.Commands(`^docker swarm join `))
+}
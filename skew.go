@@ -0,0 +1,92 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"fmt"
+	"sort"
+)
+
+// VersionReport is the Docker version of every node in the cluster compared
+// to the leader's version.
+type VersionReport struct {
+	// Leader is the hostname of the leader and LeaderVersion its version
+	Leader        string
+	LeaderVersion string
+
+	// Versions are the versions of every node keyed by hostname
+	Versions map[string]string
+
+	// Skewed are the hostnames of the nodes whose major version differs
+	// from the leader's by more than the VersionSkewTolerance
+	Skewed []string
+}
+
+// CheckVersionSkew returns the Docker version of every node in the cluster as
+// reported by the node to the managers and flags the nodes whose major
+// version differs from the leader's by more than the VersionSkewTolerance
+// (see WithVersionSkewTolerance). Nodes that have not reported a version are
+// not flagged.
+func (m *Manager) CheckVersionSkew() (VersionReport, error) {
+	nodes, err := m.GetNodes()
+	if err != nil {
+		return VersionReport{}, fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	report := VersionReport{Versions: make(map[string]string)}
+	for _, node := range nodes {
+		report.Versions[node.Hostname] = node.EngineVersion
+		if node.ManagerStatus == "Leader" {
+			report.Leader, report.LeaderVersion = node.Hostname, node.EngineVersion
+		}
+	}
+	if report.Leader == "" {
+		return VersionReport{}, fmt.Errorf("error no leader found")
+	}
+
+	leaderMajor := majorVersion(report.LeaderVersion)
+	for hostname, version := range report.Versions {
+		if version == "" {
+			continue
+		}
+
+		skew := majorVersion(version) - leaderMajor
+		if skew < 0 {
+			skew = -skew
+		}
+		if skew > m.config.VersionSkewTolerance {
+			report.Skewed = append(report.Skewed, hostname)
+		}
+	}
+	sort.Strings(report.Skewed)
+
+	return report, nil
+}
+
+// majorVersion returns the first component of a Docker version (e.g: 20 of
+// `20.10.12`) or 0 if it has none.
+func majorVersion(version string) int {
+	parts := versionParts(version)
+	if len(parts) == 0 {
+		return 0
+	}
+	return parts[0]
+}
@@ -0,0 +1,59 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestCheckVersionSkew tests that nodes whose major Docker version differs
+// from the leader's by more than the tolerance are flagged.
+func TestCheckVersionSkew(t *testing.T) {
+	assert := assert.New(t)
+
+	nodes := `{"ID": "1", "Hostname": "dm1", "EngineVersion": "20.10.12", "ManagerStatus": "Leader"}
+{"ID": "2", "Hostname": "dw1", "EngineVersion": "20.10.7", "ManagerStatus": ""}
+{"ID": "3", "Hostname": "dw2", "EngineVersion": "23.0.1", "ManagerStatus": ""}
+{"ID": "4", "Hostname": "dw3", "EngineVersion": "", "ManagerStatus": ""}
+`
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: nodes})
+
+	report, err := m.CheckVersionSkew()
+	assert.Nil(err)
+	assert.Equal("dm1", report.Leader)
+	assert.Equal("20.10.12", report.LeaderVersion)
+	assert.Equal("23.0.1", report.Versions["dw2"])
+	assert.Equal([]string{"dw2"}, report.Skewed)
+
+	m, runner = newTestManager(t, swarm.WithVersionSkewTolerance(3))
+	runner.On(`^docker node ls`, swarmtest.Response{Stdout: nodes})
+
+	report, err = m.CheckVersionSkew()
+	assert.Nil(err)
+	assert.Empty(report.Skewed)
+}
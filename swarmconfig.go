@@ -0,0 +1,143 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	swarmUpdateCommand  = `docker swarm update`
+	taskHistoryLimit    = `--task-history-limit %d`
+	dispatcherHeartbeat = `--dispatcher-heartbeat %s`
+	certExpiry          = `--cert-expiry %s`
+)
+
+// SwarmConfig holds cluster wide settings applied with `docker swarm update`.
+// Fields left as their zero value are not changed.
+type SwarmConfig struct {
+	// TaskHistoryLimit is the number of terminated tasks retained per slot
+	TaskHistoryLimit int
+
+	// DispatcherHeartbeat is the period nodes report their health at
+	DispatcherHeartbeat time.Duration
+
+	// CertExpiry is the validity period of node certificates
+	CertExpiry time.Duration
+}
+
+// SwarmSpec is the current cluster wide settings of a swarm as returned by
+// GetSwarmSpec.
+type SwarmSpec struct {
+	// TaskHistoryLimit is the number of terminated tasks retained per slot
+	TaskHistoryLimit int
+
+	// DispatcherHeartbeat is the period nodes report their health at
+	DispatcherHeartbeat time.Duration
+
+	// CertExpiry is the validity period of node certificates
+	CertExpiry time.Duration
+
+	// AutoLock is true if managers must be unlocked after they restart
+	AutoLock bool
+
+	// Raft is the Raft consensus configuration of the managers (e.g: the
+	// snapshot interval)
+	Raft RaftConfig
+}
+
+// Config returns the settings of spec that can be changed with
+// UpdateSwarmConfig so they can be modified and written back.
+func (spec SwarmSpec) Config() SwarmConfig {
+	return SwarmConfig{
+		TaskHistoryLimit:    spec.TaskHistoryLimit,
+		DispatcherHeartbeat: spec.DispatcherHeartbeat,
+		CertExpiry:          spec.CertExpiry,
+	}
+}
+
+// buildSwarmUpdateCommand builds the `docker swarm update` command for the
+// non-zero fields of cfg. An empty string is returned if there is nothing
+// to update.
+func buildSwarmUpdateCommand(cfg SwarmConfig) string {
+	var args []string
+
+	if cfg.TaskHistoryLimit != 0 {
+		args = append(args, fmt.Sprintf(taskHistoryLimit, cfg.TaskHistoryLimit))
+	}
+	if cfg.DispatcherHeartbeat != 0 {
+		args = append(args, fmt.Sprintf(dispatcherHeartbeat, cfg.DispatcherHeartbeat))
+	}
+	if cfg.CertExpiry != 0 {
+		args = append(args, fmt.Sprintf(certExpiry, cfg.CertExpiry))
+	}
+
+	if len(args) == 0 {
+		return ""
+	}
+
+	return strings.Join(append([]string{swarmUpdateCommand}, args...), " ")
+}
+
+// UpdateSwarmConfig applies the non-zero settings of cfg to the cluster from
+// the leader.
+func (m *Manager) UpdateSwarmConfig(cfg SwarmConfig) error {
+	cmd := buildSwarmUpdateCommand(cfg)
+	if cmd == "" {
+		// Nothing to update.
+		return nil
+	}
+
+	return m.onLeader(func() error {
+		if _, err := m.runMutatingCmd(cmd); err != nil {
+			return fmt.Errorf("error updating swarm config: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetSwarmSpec returns the current cluster wide settings of the swarm (e.g:
+// the task history limit and Raft snapshot interval) from a manager.
+func (m *Manager) GetSwarmSpec() (SwarmSpec, error) {
+	if err := m.ensureManager(); err != nil {
+		return SwarmSpec{}, fmt.Errorf("error connecting to manager node: %w", err)
+	}
+
+	node, err := m.GetInfo()
+	if err != nil {
+		return SwarmSpec{}, fmt.Errorf("error getting node info: %w", err)
+	}
+	if node.Swarm.Cluster == nil {
+		return SwarmSpec{}, fmt.Errorf("error no swarm cluster found")
+	}
+
+	spec := node.Swarm.Cluster.Spec
+
+	return SwarmSpec{
+		TaskHistoryLimit:    spec.Orchestration.TaskHistoryRetentionLimit,
+		DispatcherHeartbeat: spec.Dispatcher.HeartbeatPeriod,
+		CertExpiry:          spec.CAConfig.NodeCertExpiry,
+		AutoLock:            spec.EncryptionConfig.AutoLockManagers,
+		Raft:                spec.Raft,
+	}, nil
+}
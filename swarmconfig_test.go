@@ -0,0 +1,83 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+	"github.com/aucloud/go-swarm/swarmtest"
+)
+
+// TestUpdateSwarmConfigOnLeader tests that the swarm config is updated on the
+// leader when the current manager is not the leader.
+func TestUpdateSwarmConfigOnLeader(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.OnNode("10.0.0.1", `^docker node inspect .* self$`, swarmtest.Response{Stdout: `{"Leader": false, "Addr": "172.16.0.1:2377"}`})
+	runner.On(`^docker node inspect .* 1$`, swarmtest.Response{
+		Stdout: `{"ID": "1", "Description": {"Hostname": "dm2"}, "ManagerStatus": {"Leader": true, "Addr": "172.16.0.2:2377"}}`,
+	})
+	runner.On(`^docker swarm update`)
+
+	assert.Nil(m.UpdateSwarmConfig(swarm.SwarmConfig{TaskHistoryLimit: 1}))
+
+	calls := runner.Calls()
+	last := calls[len(calls)-1]
+	assert.Equal("172.16.0.2", last.Node)
+	assert.Equal("docker swarm update --task-history-limit 1", last.Cmd)
+}
+
+// TestGetSwarmSpec tests that the swarm's settings are read from a manager.
+func TestGetSwarmSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	m, runner := newTestManager(t)
+	runner.On(`^docker info`, swarmtest.Response{
+		Stdout: `{"Name": "dm1", "Swarm": {"NodeID": "1", "LocalNodeState": "active", "ControlAvailable": true, "Cluster": {"ID": "c1", "Spec": {
+			"Orchestration": {"TaskHistoryRetentionLimit": 5},
+			"Raft": {"SnapshotInterval": 10000, "KeepOldSnapshots": 0, "LogEntriesForSlowFollowers": 500, "ElectionTick": 10, "HeartbeatTick": 1},
+			"Dispatcher": {"HeartbeatPeriod": 5000000000},
+			"CAConfig": {"NodeCertExpiry": 7776000000000000},
+			"EncryptionConfig": {"AutoLockManagers": true}
+		}}}}`,
+	})
+
+	spec, err := m.GetSwarmSpec()
+	assert.Nil(err)
+	assert.Equal(swarm.SwarmSpec{
+		TaskHistoryLimit:    5,
+		DispatcherHeartbeat: time.Second * 5,
+		CertExpiry:          time.Hour * 24 * 90,
+		AutoLock:            true,
+		Raft: swarm.RaftConfig{
+			SnapshotInterval:           10000,
+			LogEntriesForSlowFollowers: 500,
+			ElectionTick:               10,
+			HeartbeatTick:              1,
+		},
+	}, spec)
+	assert.Equal(5, spec.Config().TaskHistoryLimit)
+}
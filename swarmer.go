@@ -0,0 +1,39 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+// Swarmer is the interface of the operations on a Docker Swarm cluster that
+// are implemented by Manager.
+type Swarmer interface {
+	GetInfo() (NodeInfo, error)
+	GetManagers() ([]NodeInfo, error)
+	GetNodes() (Nodes, error)
+
+	CreateSwarm(vms VMNodes, force bool) error
+	CreateSwarmResult(vms VMNodes, force bool) (CreateResult, error)
+	UpdateSwarm(vms VMNodes) error
+
+	DrainNode(node string) (DrainResult, error)
+	DrainNodes(nodes []string) ([]DrainResult, error)
+	RemoveNodes(hostnames []string) error
+}
+
+var _ Swarmer = (*Manager)(nil)
@@ -0,0 +1,247 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package swarmtest provides test doubles for the Switcher and Runner used by
+// swarm.Manager so that code built on top of the swarm package can be unit
+// tested without real Docker nodes.
+package swarmtest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aucloud/go-runcmd"
+)
+
+// Response is a canned response to a command run by a FakeRunner
+type Response struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+
+	// Delay is how long the command takes to complete
+	Delay time.Duration
+}
+
+// ExitError is returned by a command whose canned Response has a non-zero
+// ExitCode.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitStatus returns the exit code of the command
+func (e *ExitError) ExitStatus() int {
+	return e.Code
+}
+
+// Call records a single command run on a node
+type Call struct {
+	Node string
+	Cmd  string
+}
+
+type rule struct {
+	node      string
+	pattern   *regexp.Regexp
+	responses []Response
+}
+
+// FakeRunner runs commands by matching them against patterns registered with
+// On() or OnNode() and returning the canned responses. Commands that do not
+// match any pattern fail with exit code 127. All commands run are recorded.
+type FakeRunner struct {
+	sync.Mutex
+
+	rules []*rule
+	calls []Call
+}
+
+// NewFakeRunner constructs a new FakeRunner with no canned responses
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{}
+}
+
+// On registers responses for commands run on any node matching the regular
+// expression pattern. Responses are returned in order with the last response
+// repeated for any further matching commands. Patterns registered later take
+// precedence over earlier ones.
+func (r *FakeRunner) On(pattern string, responses ...Response) {
+	r.OnNode("", pattern, responses...)
+}
+
+// OnNode is like On but only matches commands run on the given node
+func (r *FakeRunner) OnNode(node, pattern string, responses ...Response) {
+	r.Lock()
+	defer r.Unlock()
+
+	if len(responses) == 0 {
+		responses = []Response{{}}
+	}
+
+	r.rules = append(r.rules, &rule{
+		node:      node,
+		pattern:   regexp.MustCompile(pattern),
+		responses: responses,
+	})
+}
+
+// Calls returns all commands run so far
+func (r *FakeRunner) Calls() []Call {
+	r.Lock()
+	defer r.Unlock()
+
+	calls := make([]Call, len(r.calls))
+	copy(calls, r.calls)
+	return calls
+}
+
+// Commands returns all commands run so far matching the regular expression
+// pattern
+func (r *FakeRunner) Commands(pattern string) []string {
+	re := regexp.MustCompile(pattern)
+
+	var cmds []string
+	for _, call := range r.Calls() {
+		if re.MatchString(call.Cmd) {
+			cmds = append(cmds, call.Cmd)
+		}
+	}
+	return cmds
+}
+
+func (r *FakeRunner) respond(node, cmd string) Response {
+	r.Lock()
+	defer r.Unlock()
+
+	r.calls = append(r.calls, Call{Node: node, Cmd: cmd})
+
+	for i := len(r.rules) - 1; i >= 0; i-- {
+		rule := r.rules[i]
+		if rule.node != "" && rule.node != node {
+			continue
+		}
+		if !rule.pattern.MatchString(cmd) {
+			continue
+		}
+
+		res := rule.responses[0]
+		if len(rule.responses) > 1 {
+			rule.responses = rule.responses[1:]
+		}
+		return res
+	}
+
+	return Response{
+		Stderr:   fmt.Sprintf("swarmtest: no canned response for command: %s", cmd),
+		ExitCode: 127,
+	}
+}
+
+// Runner returns a runcmd.Runner that runs commands against the FakeRunner
+// recording them as having run on the given node.
+func (r *FakeRunner) Runner(node string) runcmd.Runner {
+	return &nodeRunner{runner: r, node: node}
+}
+
+type nodeRunner struct {
+	runner *FakeRunner
+	node   string
+}
+
+func (r *nodeRunner) Command(cmd string) (runcmd.CmdWorker, error) {
+	return &worker{runner: r.runner, node: r.node, cmd: cmd}, nil
+}
+
+var _ runcmd.CmdWorker = (*worker)(nil)
+
+type worker struct {
+	runner *FakeRunner
+	node   string
+	cmd    string
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (w *worker) Run() ([]string, error) {
+	stdout := &bytes.Buffer{}
+	w.SetStdout(stdout)
+
+	if err := w.Start(); err != nil {
+		return nil, err
+	}
+	err := w.Wait()
+
+	return strings.Split(strings.TrimSpace(stdout.String()), "\n"), err
+}
+
+func (w *worker) Start() error { return nil }
+
+func (w *worker) Wait() error {
+	res := w.runner.respond(w.node, w.cmd)
+	time.Sleep(res.Delay)
+
+	if w.stdout != nil {
+		io.WriteString(w.stdout, res.Stdout)
+	}
+	if w.stderr != nil {
+		io.WriteString(w.stderr, res.Stderr)
+	}
+
+	if res.ExitCode != 0 {
+		return &ExitError{Code: res.ExitCode}
+	}
+
+	return nil
+}
+
+func (w *worker) StdinPipe() (io.WriteCloser, error) {
+	r, wc := io.Pipe()
+	w.stdin = r
+	return wc, nil
+}
+
+func (w *worker) StdoutPipe() (io.Reader, error) {
+	r, wc := io.Pipe()
+	w.stdout = wc
+	return r, nil
+}
+
+func (w *worker) StderrPipe() (io.Reader, error) {
+	r, wc := io.Pipe()
+	w.stderr = wc
+	return r, nil
+}
+
+func (w *worker) SetStdout(buffer io.Writer) { w.stdout = buffer }
+func (w *worker) SetStderr(buffer io.Writer) { w.stderr = buffer }
+func (w *worker) SetStdin(buffer io.Reader)  { w.stdin = buffer }
+
+func (w *worker) GetCommandLine() string { return w.cmd }
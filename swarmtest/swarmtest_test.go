@@ -0,0 +1,62 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarmtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+)
+
+const testInfo = `{"Name": "dm1", "ServerVersion": "20.10.12", "Swarm": {"LocalNodeState": "inactive"}}`
+
+// TestFakes tests that a Manager can be driven by the FakeSwitcher and
+// FakeRunner test doubles.
+func TestFakes(t *testing.T) {
+	assert := assert.New(t)
+
+	runner := NewFakeRunner()
+	runner.OnNode("10.0.0.1", `^docker info`, Response{Stdout: testInfo})
+
+	switcher := NewFakeSwitcher(runner)
+
+	m, err := swarm.NewManager(switcher)
+	assert.Nil(err)
+
+	assert.Nil(m.SwitchNode("10.0.0.1"))
+
+	node, err := m.GetInfo()
+	assert.Nil(err)
+	assert.Equal("dm1", node.Name)
+	assert.False(node.IsManager())
+
+	// Not a manager and no remote managers to connect to
+	_, err = m.GetNodes()
+	assert.Error(err)
+
+	assert.Equal([]string{"10.0.0.1"}, switcher.Switches())
+	assert.Equal([]Call{
+		{Node: "10.0.0.1", Cmd: `docker info --format "{{ json . }}"`},
+		{Node: "10.0.0.1", Cmd: `docker info --format "{{ json . }}"`},
+	}, runner.Calls())
+}
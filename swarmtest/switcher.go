@@ -0,0 +1,149 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarmtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aucloud/go-runcmd"
+
+	swarm "github.com/aucloud/go-swarm"
+)
+
+// FakeSwitcher is a swarm.Switcher that records the nodes switched to and
+// runs all commands against a FakeRunner.
+type FakeSwitcher struct {
+	sync.RWMutex
+
+	parent   *FakeSwitcher
+	runner   *FakeRunner
+	node     string
+	switches []string
+	failures map[string]error
+	hangs    map[string]bool
+}
+
+// NewFakeSwitcher constructs a new FakeSwitcher that runs commands against
+// the given FakeRunner
+func NewFakeSwitcher(runner *FakeRunner) *FakeSwitcher {
+	return &FakeSwitcher{runner: runner, failures: make(map[string]error)}
+}
+
+// FailSwitch causes switching to the given node to fail with err
+func (s *FakeSwitcher) FailSwitch(nodeAddr string, err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.failures[nodeAddr] = err
+}
+
+// HangSwitch causes switching to the given node to block until the switch's
+// context is done (e.g: a node that is down and times out)
+func (s *FakeSwitcher) HangSwitch(nodeAddr string) {
+	s.Lock()
+	defer s.Unlock()
+	if s.hangs == nil {
+		s.hangs = make(map[string]bool)
+	}
+	s.hangs[nodeAddr] = true
+}
+
+// Switches returns the nodes switched to so far
+func (s *FakeSwitcher) Switches() []string {
+	s.RLock()
+	defer s.RUnlock()
+
+	switches := make([]string, len(s.switches))
+	copy(switches, s.switches)
+	return switches
+}
+
+// Node returns the node currently switched to
+func (s *FakeSwitcher) Node() string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.node
+}
+
+func (s *FakeSwitcher) String() string {
+	return fmt.Sprintf("fake://%s", s.Node())
+}
+
+func (s *FakeSwitcher) Switch(ctx context.Context, nodeAddr string) error {
+	if s.parent != nil {
+		s.parent.record(nodeAddr)
+	}
+
+	s.Lock()
+	s.switches = append(s.switches, nodeAddr)
+	hang := s.hangs[nodeAddr]
+	err, failed := s.failures[nodeAddr]
+	if !hang && !failed {
+		s.node = nodeAddr
+	}
+	s.Unlock()
+
+	if hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	return err
+}
+
+func (s *FakeSwitcher) SwitchVia(ctx context.Context, nodeAddr string) error {
+	return s.Switch(ctx, nodeAddr)
+}
+
+func (s *FakeSwitcher) Runner() runcmd.Runner {
+	return s.runner.Runner(s.Node())
+}
+
+func (s *FakeSwitcher) record(nodeAddr string) {
+	if s.parent != nil {
+		s.parent.record(nodeAddr)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.switches = append(s.switches, nodeAddr)
+}
+
+// Clone returns a new FakeSwitcher running commands against the same
+// FakeRunner with the same switch failures and hangs. Switches made by the clone are
+// also recorded by s.
+func (s *FakeSwitcher) Clone() swarm.Switcher {
+	s.RLock()
+	defer s.RUnlock()
+
+	failures := make(map[string]error, len(s.failures))
+	for addr, err := range s.failures {
+		failures[addr] = err
+	}
+
+	hangs := make(map[string]bool, len(s.hangs))
+	for addr := range s.hangs {
+		hangs[addr] = true
+	}
+
+	return &FakeSwitcher{parent: s, runner: s.runner, failures: failures, hangs: hangs}
+}
@@ -42,6 +42,13 @@ type Switcher interface {
 	Runner() runcmd.Runner
 }
 
+// Cloner is an optional interface implemented by Switchers that can create
+// an independent copy of themselves with their own connection so that more
+// than one node can be operated on at the same time.
+type Cloner interface {
+	Clone() Switcher
+}
+
 type nullSwitcher struct{}
 
 func NewNullSwitcher() (Switcher, error)                                 { return &nullSwitcher{}, nil }
@@ -49,6 +56,7 @@ func (s *nullSwitcher) String() string                                   { retur
 func (s *nullSwitcher) Switch(ctx context.Context, addr string) error    { return nil }
 func (s *nullSwitcher) SwitchVia(ctx context.Context, addr string) error { return nil }
 func (s *nullSwitcher) Runner() runcmd.Runner                            { return nil }
+func (s *nullSwitcher) Clone() Switcher                                  { return &nullSwitcher{} }
 
 type localSwitcher struct {
 	sync.RWMutex
@@ -56,9 +64,15 @@ type localSwitcher struct {
 }
 
 // NewLocalSwitcher constructs a new Switcher that talks directly to a local
-// Docker UNIX Socket on a single-node
+// Docker UNIX Socket on a single-node (e.g: a development or CI swarm created
+// with CreateSwarm forced to a single manager). Switching nodes is a no-op as
+// every command is run locally whatever the node's address.
 func NewLocalSwitcher() (Switcher, error) {
-	return &localSwitcher{}, nil
+	s := &localSwitcher{}
+	if err := s.Switch(context.Background(), ""); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
 func (s *localSwitcher) String() string {
@@ -72,15 +86,20 @@ func (s *localSwitcher) Runner() runcmd.Runner {
 }
 
 func (s *localSwitcher) Switch(ctx context.Context, host string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.runner != nil {
+		log.Debugf("not switching to %s: running commands locally", host)
+		return nil
+	}
+
 	runner, err := runcmd.NewLocalRunner()
 	if err != nil {
 		log.WithError(err).Error("error creating local runner")
 		return fmt.Errorf("error creating local runner: %w", err)
 	}
-
-	s.Lock()
 	s.runner = runner
-	s.Unlock()
 
 	return nil
 }
@@ -89,6 +108,14 @@ func (s *localSwitcher) SwitchVia(ctx context.Context, host string) error {
 	return s.Switch(ctx, host)
 }
 
+// Clone returns a localSwitcher sharing the local runner as there is no
+// connection to copy.
+func (s *localSwitcher) Clone() Switcher {
+	s.RLock()
+	defer s.RUnlock()
+	return &localSwitcher{runner: s.runner}
+}
+
 type sshSwitcher struct {
 	sync.RWMutex
 	runner runcmd.Runner
@@ -153,12 +180,16 @@ func (s *sshSwitcher) Switch(ctx context.Context, nodeAddr string) error {
 
 	s.Lock()
 	s.addr = addr
+	s.jump = ""
 	s.runner = runner
 	s.Unlock()
 
 	return nil
 }
 
+// SwitchVia switches to nodeAddr by jumping through the current node or, if
+// the current node was itself switched to via a jump host, through the same
+// jump host as the current node may not be reachable directly.
 func (s *sshSwitcher) SwitchVia(ctx context.Context, nodeAddr string) error {
 	_, port, err := net.SplitHostPort(s.addr)
 	if err != nil {
@@ -174,16 +205,35 @@ func (s *sshSwitcher) SwitchVia(ctx context.Context, nodeAddr string) error {
 
 	addr := fmt.Sprintf("%s:%s", nodeAddr, port)
 
-	runner, err := runcmd.NewRemoteKeyAuthRunnerViaJumphost(ctx, s.user, addr, s.addr, s.key)
+	jump := s.addr
+	if s.jump != "" {
+		jump = s.jump
+	}
+
+	runner, err := runcmd.NewRemoteKeyAuthRunnerViaJumphost(ctx, s.user, addr, jump, s.key)
 	if err != nil {
 		return fmt.Errorf("error creating remote runner: %w", err)
 	}
 
 	s.Lock()
-	s.jump = s.addr
+	s.jump = jump
 	s.addr = addr
 	s.runner = runner
 	s.Unlock()
 
 	return nil
 }
+
+// Clone returns a new sshSwitcher with the same credentials and jump host
+// that is not yet connected to any node.
+func (s *sshSwitcher) Clone() Switcher {
+	s.RLock()
+	defer s.RUnlock()
+
+	return &sshSwitcher{
+		user: s.user,
+		addr: s.addr,
+		jump: s.jump,
+		key:  s.key,
+	}
+}
@@ -0,0 +1,64 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSSHSwitcherClone tests that a clone of a sshSwitcher connected via a
+// jump host keeps the jump host so it is switched to through it again.
+func TestSSHSwitcherClone(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &sshSwitcher{user: "admin", addr: "10.0.0.2:22", jump: "203.0.113.1:22", key: "id_rsa"}
+
+	c, ok := s.Clone().(*sshSwitcher)
+	assert.True(ok)
+	assert.Equal(&sshSwitcher{user: "admin", addr: "10.0.0.2:22", jump: "203.0.113.1:22", key: "id_rsa"}, c)
+	assert.Nil(c.Runner())
+}
+
+// TestLocalSwitcher tests that the local runner is created once and kept
+// however many times the localSwitcher is switched.
+func TestLocalSwitcher(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := NewLocalSwitcher()
+	assert.NoError(err)
+
+	runner := s.Runner()
+	assert.NotNil(runner)
+	assert.Same(runner, s.Runner())
+
+	assert.NoError(s.Switch(context.Background(), "10.0.0.2"))
+	assert.Same(runner, s.Runner())
+
+	assert.NoError(s.SwitchVia(context.Background(), "10.0.0.3"))
+	assert.Same(runner, s.Runner())
+
+	clone := s.(Cloner).Clone()
+	assert.NotNil(clone.Runner())
+	assert.Same(runner, clone.Runner())
+}
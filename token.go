@@ -0,0 +1,80 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// TokenProvider provides the join tokens of type "manager" or "worker" that
+// nodes use to join a swarm (e.g: from a secrets manager). The default
+// fetches them from a manager with `docker swarm join-token` (see
+// Manager.JoinToken).
+type TokenProvider interface {
+	JoinToken(tokenType string) (string, error)
+}
+
+// TokenProviderFunc is an adapter to use an ordinary function as a
+// TokenProvider.
+type TokenProviderFunc func(tokenType string) (string, error)
+
+// JoinToken calls f(tokenType)
+func (f TokenProviderFunc) JoinToken(tokenType string) (string, error) {
+	return f(tokenType)
+}
+
+// FileTokenProvider is a TokenProvider that reads the join tokens from the
+// files named "manager" and "worker" in Dir (e.g: secrets mounted into a
+// container).
+type FileTokenProvider struct {
+	Dir string
+}
+
+// JoinToken returns the contents of the file named tokenType in Dir
+func (p FileTokenProvider) JoinToken(tokenType string) (string, error) {
+	if tokenType != managerToken && tokenType != workerToken {
+		return "", fmt.Errorf("error invalid token type %q", tokenType)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(p.Dir, tokenType))
+	if err != nil {
+		return "", fmt.Errorf("error reading %s join token: %w", tokenType, err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("error %s join token is empty", tokenType)
+	}
+
+	return token, nil
+}
+
+// joinToken returns the join token of the given type from the configured
+// TokenProvider or from a manager if there is none.
+func (m *Manager) joinToken(tokenType string) (string, error) {
+	if m.config.TokenProvider != nil {
+		return m.config.TokenProvider.JoinToken(tokenType)
+	}
+	return m.JoinToken(tokenType)
+}
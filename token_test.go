@@ -0,0 +1,52 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aucloud/go-swarm"
+)
+
+// TestFileTokenProvider tests that FileTokenProvider reads the token of each
+// type from its own file.
+func TestFileTokenProvider(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	assert.Nil(ioutil.WriteFile(filepath.Join(dir, "worker"), []byte("SWMTKN-1-worker\n"), 0600))
+
+	provider := swarm.FileTokenProvider{Dir: dir}
+
+	token, err := provider.JoinToken("worker")
+	assert.Nil(err)
+	assert.Equal("SWMTKN-1-worker", token)
+
+	_, err = provider.JoinToken("manager")
+	assert.Error(err)
+
+	_, err = provider.JoinToken("../worker")
+	assert.Error(err)
+}
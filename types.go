@@ -21,12 +21,74 @@
 package swarm
 
 import (
+	"fmt"
 	"strings"
+	"time"
 )
 
 type ClusterInfo struct {
 	ID        string
 	CreatedAt string
+
+	Spec    ClusterSpec
+	TLSInfo TLSInfo
+
+	// RootRotationInProgress is true while the swarm's root CA is being
+	// rotated (e.g: by `docker swarm ca --rotate`)
+	RootRotationInProgress bool
+}
+
+// ClusterSpec is the user-defined configuration of a swarm
+type ClusterSpec struct {
+	Orchestration    OrchestrationConfig
+	Raft             RaftConfig
+	Dispatcher       DispatcherConfig
+	CAConfig         CAConfig
+	EncryptionConfig EncryptionConfig
+}
+
+// OrchestrationConfig is the orchestration configuration of a swarm
+type OrchestrationConfig struct {
+	// TaskHistoryRetentionLimit is the number of terminated tasks retained
+	// per slot
+	TaskHistoryRetentionLimit int
+}
+
+// RaftConfig is the Raft consensus configuration of a swarm's managers
+type RaftConfig struct {
+	SnapshotInterval           uint64
+	KeepOldSnapshots           uint64
+	LogEntriesForSlowFollowers uint64
+	ElectionTick               int
+	HeartbeatTick              int
+}
+
+// DispatcherConfig is the dispatcher configuration of a swarm
+type DispatcherConfig struct {
+	// HeartbeatPeriod is the period nodes report their health at
+	HeartbeatPeriod time.Duration
+}
+
+// EncryptionConfig is the encryption configuration of a swarm
+type EncryptionConfig struct {
+	// AutoLockManagers is true if managers must be unlocked with the
+	// swarm's unlock key after they restart
+	AutoLockManagers bool
+}
+
+// CAConfig is the certificate authority configuration of a swarm
+type CAConfig struct {
+	// NodeCertExpiry is the validity period of node certificates
+	NodeCertExpiry time.Duration
+}
+
+// TLSInfo is the TLS configuration of a swarm
+type TLSInfo struct {
+	// TrustRoot is the PEM encoded root CA certificate of the swarm
+	TrustRoot string
+
+	CertIssuerSubject   string
+	CertIssuerPublicKey string
 }
 
 type RemoteManager struct {
@@ -40,11 +102,55 @@ type SwarmInfo struct {
 	LocalNodeState   string
 	ControlAvailable bool
 
+	// Error is the reason the node is in an error or locked state
+	Error string
+
+	// Nodes and Managers are the number of nodes and managers in the swarm
+	// and are only reported by managers
 	Nodes          int
 	Managers       int
 	RemoteManagers []RemoteManager
 
-	Cluster ClusterInfo
+	// Cluster is only present on manager nodes of a swarm
+	Cluster *ClusterInfo
+}
+
+// ClusterID returns the ID of the swarm cluster or an empty string if the
+// node is not a manager of a swarm.
+func (s SwarmInfo) ClusterID() string {
+	if s.Cluster == nil {
+		return ""
+	}
+	return s.Cluster.ID
+}
+
+// Workers returns the number of workers in the swarm as reported by a
+// manager.
+func (s SwarmInfo) Workers() int {
+	return s.Nodes - s.Managers
+}
+
+// Counts returns the number of managers and workers in the swarm as reported
+// by a manager.
+func (s SwarmInfo) Counts() NodeCounts {
+	return NodeCounts{Managers: s.Managers, Workers: s.Workers()}
+}
+
+// NodeCounts is the number of managers and workers in a swarm and can be
+// used to compare a live cluster (SwarmInfo.Counts) against a Clusterfile
+// (VMNodes.Counts) without listing its nodes.
+type NodeCounts struct {
+	Managers int
+	Workers  int
+}
+
+// Total returns the total number of nodes
+func (c NodeCounts) Total() int {
+	return c.Managers + c.Workers
+}
+
+func (c NodeCounts) String() string {
+	return fmt.Sprintf("%d nodes (%d managers, %d workers)", c.Total(), c.Managers, c.Workers)
 }
 
 type NodeInfo struct {
@@ -63,12 +169,36 @@ type NodeInfo struct {
 	ServerVersion string
 
 	Swarm SwarmInfo
+
+	// ManagerStatus is not part of `docker info` and is not populated by
+	// GetInfo. Use Manager.IsLeader to check whether the current node is the
+	// leader.
+	ManagerStatus NodeManagerStatus `json:"-"`
 }
 
 func (node NodeInfo) IsManager() bool {
 	return node.Swarm.ControlAvailable
 }
 
+// IsWorker returns true if the node is an active member of a swarm that is
+// not a manager. Nodes that are not part of a swarm are neither.
+func (node NodeInfo) IsWorker() bool {
+	return node.Swarm.LocalNodeState == "active" && !node.Swarm.ControlAvailable
+}
+
+// IsLocked returns true if the node is a manager of a swarm with autolock
+// enabled that has restarted and must be unlocked with the unlock key
+// (`docker swarm unlock`) before it can rejoin the swarm.
+func (node NodeInfo) IsLocked() bool {
+	return node.Swarm.LocalNodeState == "locked"
+}
+
+// IsLeader returns true if the node is the current leader of the swarm's
+// managers. ManagerStatus must be set for this to ever be true.
+func (node NodeInfo) IsLeader() bool {
+	return node.IsManager() && node.ManagerStatus.Leader
+}
+
 type NodeStatus struct {
 	ID            string
 	Hostname      string
@@ -76,10 +206,181 @@ type NodeStatus struct {
 	Availability  string
 	ManagerStatus string
 	Status        string
+
+	// TLSStatus is "Ready" or "Needs Rotation" while the node's certificate
+	// has not been issued by the current root CA
+	TLSStatus string
+}
+
+// Role returns the Swarm role of the node, either ManagerRole or WorkerRole
+func (node NodeStatus) Role() string {
+	if node.ManagerStatus != "" {
+		return ManagerRole
+	}
+	return WorkerRole
+}
+
+// GetAvailability returns the Availability of the node (which is reported
+// capitalised, e.g: "Drain") or an empty Availability if it is unknown.
+func (node NodeStatus) GetAvailability() Availability {
+	availability, _ := ParseAvailability(node.Availability)
+	return availability
+}
+
+// Availability is the availability of a node which determines whether the
+// node is assigned tasks.
+type Availability string
+
+const (
+	// AvailabilityActive nodes are assigned new tasks
+	AvailabilityActive Availability = "active"
+
+	// AvailabilityPause nodes keep their tasks but are not assigned new tasks
+	AvailabilityPause Availability = "pause"
+
+	// AvailabilityDrain nodes are not assigned new tasks and their existing
+	// tasks are rescheduled on other nodes
+	AvailabilityDrain Availability = "drain"
+)
+
+// availabilities are the valid values of Availability
+var availabilities = []Availability{AvailabilityActive, AvailabilityPause, AvailabilityDrain}
+
+// ParseAvailability parses s (e.g: "drain" or "Drain" as reported by
+// `docker node ls`) into an Availability.
+func ParseAvailability(s string) (Availability, error) {
+	for _, availability := range availabilities {
+		if strings.EqualFold(s, string(availability)) {
+			return availability, nil
+		}
+	}
+
+	var valid []string
+	for _, availability := range availabilities {
+		valid = append(valid, availability.String())
+	}
+
+	return "", fmt.Errorf("invalid availability %q (expected one of %s)", s, strings.Join(valid, ", "))
+}
+
+func (a Availability) String() string {
+	return string(a)
 }
 
 type Nodes []NodeStatus
 
+// Ready returns the number of nodes that are ready
+func (ns Nodes) Ready() int {
+	var n int
+	for _, node := range ns {
+		if node.Status == "Ready" {
+			n++
+		}
+	}
+	return n
+}
+
+// FindByHostname returns the node with the given hostname and whether it was
+// found. If more than one node has the hostname (e.g: a node was replaced
+// and the old node is still Down) the only Ready node is returned, otherwise
+// ErrAmbiguousNode is returned.
+func (ns Nodes) FindByHostname(hostname string) (NodeStatus, bool, error) {
+	var matches, ready Nodes
+
+	for _, node := range ns {
+		if node.Hostname != hostname {
+			continue
+		}
+		matches = append(matches, node)
+		if node.Status == "Ready" {
+			ready = append(ready, node)
+		}
+	}
+
+	switch {
+	case len(matches) == 0:
+		return NodeStatus{}, false, nil
+	case len(matches) == 1:
+		return matches[0], true, nil
+	case len(ready) == 1:
+		return ready[0], true, nil
+	}
+
+	var ids []string
+	for _, node := range matches {
+		ids = append(ids, node.ID)
+	}
+
+	return NodeStatus{}, false, fmt.Errorf("%w: %s (%s)", ErrAmbiguousNode, hostname, strings.Join(ids, ","))
+}
+
+// NodeSpec is the user-defined specification of a node as returned by
+// `docker node inspect`.
+type NodeSpec struct {
+	Labels       map[string]string
+	Role         string
+	Availability string
+}
+
+type NodeDescription struct {
+	Hostname  string
+	Resources Resources
+}
+
+// Resources are an amount of CPU (in billionths of a CPU) and memory (in
+// bytes) such as the resources of a node or reserved by a task.
+type Resources struct {
+	NanoCPUs    int64
+	MemoryBytes int64
+}
+
+// Add returns the sum of r and other
+func (r Resources) Add(other Resources) Resources {
+	return Resources{NanoCPUs: r.NanoCPUs + other.NanoCPUs, MemoryBytes: r.MemoryBytes + other.MemoryBytes}
+}
+
+// Sub returns r less other
+func (r Resources) Sub(other Resources) Resources {
+	return Resources{NanoCPUs: r.NanoCPUs - other.NanoCPUs, MemoryBytes: r.MemoryBytes - other.MemoryBytes}
+}
+
+// Fits returns true if other fits within r
+func (r Resources) Fits(other Resources) bool {
+	return other.NanoCPUs <= r.NanoCPUs && other.MemoryBytes <= r.MemoryBytes
+}
+
+type NodeState struct {
+	State   string
+	Message string
+	Addr    string
+}
+
+type NodeManagerStatus struct {
+	Leader       bool
+	Reachability string
+	Addr         string
+}
+
+// NodeDetail represents the detailed information of a single node in the
+// cluster as returned by `docker node inspect`.
+type NodeDetail struct {
+	ID            string
+	Spec          NodeSpec
+	Description   NodeDescription
+	Status        NodeState
+	ManagerStatus NodeManagerStatus
+}
+
+func (node NodeDetail) Hostname() string {
+	return node.Description.Hostname
+}
+
+// Note returns the free-form description of the node from its
+// DescriptionLabel (see DescriptionTag) or an empty string if it has none.
+func (node NodeDetail) Note() string {
+	return node.Spec.Labels[DescriptionLabel]
+}
+
 type TaskStatus struct {
 	ID           string
 	Name         string
@@ -91,17 +392,138 @@ type TaskStatus struct {
 	DesiredState string
 }
 
+// ServiceName returns the name of the service the task belongs to. Task
+// names are of the form `<service>.<slot>` for replicated services and
+// `<service>.<node id>` for global services.
+func (t TaskStatus) ServiceName() string {
+	if i := strings.LastIndex(t.Name, "."); i > 0 {
+		return t.Name[:i]
+	}
+	return t.Name
+}
+
 func (t TaskStatus) Shutdown() bool {
 	return strings.HasPrefix(strings.ToLower(t.CurrentState), "shutdown")
 }
 
+// terminalStates are the task states a task never leaves
+var terminalStates = []string{"shutdown", "complete", "failed", "rejected", "orphaned", "remove"}
+
+// Terminated returns true if the task is in a terminal state (e.g: shutdown
+// or complete for one-shot tasks) and will not run again.
+func (t TaskStatus) Terminated() bool {
+	state := strings.ToLower(t.CurrentState)
+	for _, terminal := range terminalStates {
+		if strings.HasPrefix(state, terminal) {
+			return true
+		}
+	}
+	return false
+}
+
+// Running returns true if the task is currently running
+func (t TaskStatus) Running() bool {
+	return strings.HasPrefix(strings.ToLower(t.CurrentState), "running")
+}
+
 type Tasks []TaskStatus
 
+// Active returns the number of tasks that have not terminated
+func (ts Tasks) Active() int {
+	var n int
+	for _, t := range ts {
+		if !t.Terminated() {
+			n++
+		}
+	}
+	return n
+}
+
+// AllShutdown returns true if every task has terminated
 func (ts Tasks) AllShutdown() bool {
 	for _, t := range ts {
-		if !t.Shutdown() {
+		if !t.Terminated() {
 			return false
 		}
 	}
 	return true
 }
+
+// WithoutServices returns the tasks that do not belong to any of services
+func (ts Tasks) WithoutServices(services []string) Tasks {
+	if len(services) == 0 {
+		return ts
+	}
+
+	var tasks Tasks
+	for _, t := range ts {
+		if !HasString(services, t.ServiceName()) {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks
+}
+
+// AllRunning returns true if every task is running
+func (ts Tasks) AllRunning() bool {
+	for _, t := range ts {
+		if !t.Running() {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	// GlobalMode is the mode of services with one task on every node
+	GlobalMode = "global"
+
+	// ReplicatedMode is the mode of services with a number of replicas
+	ReplicatedMode = "replicated"
+)
+
+// ServiceStatus is a service in the cluster as returned by
+// `docker service ls`.
+type ServiceStatus struct {
+	ID       string
+	Name     string
+	Mode     string
+	Replicas string
+	Image    string
+	Ports    string
+}
+
+type Services []ServiceStatus
+
+// Network is a swarm scoped network (e.g: an overlay network) as returned by
+// `docker network ls`.
+type Network struct {
+	ID     string
+	Name   string
+	Driver string
+	Scope  string
+}
+
+// CreateResult summarises the swarm cluster created by CreateSwarmResult.
+// Managers and Workers are the hostnames of the nodes of the Clusterfile that
+// are members of the cluster.
+type CreateResult struct {
+	ClusterID string
+	Leader    string
+	Managers  []string
+	Workers   []string
+}
+
+// DrainResult describes the outcome of draining a single node including the
+// tasks that were running on the node when the drain started and the
+// services they belong to.
+type DrainResult struct {
+	Node     string
+	Tasks    []string
+	Services []string
+	Elapsed  time.Duration
+
+	// Stuck are the names of the tasks still on the node if the drain
+	// timed out
+	Stuck []string
+}
@@ -0,0 +1,155 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNodeInfoRoles tests the `NodeInfo.IsManager()`, `NodeInfo.IsWorker()`
+// and `NodeInfo.IsLeader()` predicates.
+func TestNodeInfoRoles(t *testing.T) {
+	assert := assert.New(t)
+
+	// A node that is not part of any swarm is neither a manager nor a worker
+	node := NodeInfo{Swarm: SwarmInfo{LocalNodeState: "inactive"}}
+	assert.False(node.IsManager())
+	assert.False(node.IsWorker())
+	assert.False(node.IsLeader())
+
+	node = NodeInfo{Swarm: SwarmInfo{LocalNodeState: "active"}}
+	assert.False(node.IsManager())
+	assert.True(node.IsWorker())
+	assert.False(node.IsLeader())
+
+	node = NodeInfo{Swarm: SwarmInfo{LocalNodeState: "active", ControlAvailable: true}}
+	assert.True(node.IsManager())
+	assert.False(node.IsWorker())
+	assert.False(node.IsLeader())
+
+	node.ManagerStatus.Leader = true
+	assert.True(node.IsLeader())
+
+	// A locked manager is neither until it is unlocked
+	node = NodeInfo{Swarm: SwarmInfo{LocalNodeState: "locked"}}
+	assert.True(node.IsLocked())
+	assert.False(node.IsManager())
+	assert.False(node.IsWorker())
+}
+
+// TestNodeCounts tests comparing the node counts reported by a manager
+// against the nodes of a Clusterfile.
+func TestNodeCounts(t *testing.T) {
+	assert := assert.New(t)
+
+	info := SwarmInfo{Nodes: 5, Managers: 3}
+	assert.Equal(2, info.Workers())
+	assert.Equal(NodeCounts{Managers: 3, Workers: 2}, info.Counts())
+	assert.Equal(5, info.Counts().Total())
+	assert.Equal("5 nodes (3 managers, 2 workers)", info.Counts().String())
+
+	vms := VMNodes{
+		{Hostname: "dm1", Tags: map[string]string{RoleTag: ManagerRole}},
+		{Hostname: "dm2", Tags: map[string]string{RoleTag: ManagerRole}},
+		{Hostname: "dm3", Tags: map[string]string{RoleTag: ManagerRole}},
+		{Hostname: "dw1", Tags: map[string]string{RoleTag: WorkerRole}},
+	}
+	assert.NotEqual(vms.Counts(), info.Counts())
+
+	vms = append(vms, VMNode{Hostname: "dw2", Tags: map[string]string{RoleTag: WorkerRole}})
+	assert.Equal(vms.Counts(), info.Counts())
+}
+
+// TestParseAvailability tests parsing availabilities as they appear in a
+// Clusterfile and as reported by `docker node ls`.
+func TestParseAvailability(t *testing.T) {
+	assert := assert.New(t)
+
+	availability, err := ParseAvailability("drain")
+	assert.Nil(err)
+	assert.Equal(AvailabilityDrain, availability)
+
+	availability, err = ParseAvailability("Active")
+	assert.Nil(err)
+	assert.Equal(AvailabilityActive, availability)
+	assert.Equal("active", availability.String())
+
+	_, err = ParseAvailability("drained")
+	assert.Error(err)
+}
+
+// TestTaskServiceName tests deriving a task's service name from its name.
+func TestTaskServiceName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("web", TaskStatus{Name: "web.1"}.ServiceName())
+	assert.Equal("stack_agent", TaskStatus{Name: "stack_agent.x8k2j1"}.ServiceName())
+	assert.Equal("web", TaskStatus{Name: "web"}.ServiceName())
+}
+
+// TestFindByHostname tests resolving a node by hostname including when the
+// hostname matches more than one node.
+func TestFindByHostname(t *testing.T) {
+	assert := assert.New(t)
+
+	nodes := Nodes{
+		{ID: "1", Hostname: "dm1", Status: "Ready"},
+		{ID: "2", Hostname: "dw1", Status: "Down"},
+		{ID: "3", Hostname: "dw1", Status: "Ready"},
+		{ID: "4", Hostname: "dw2", Status: "Down"},
+		{ID: "5", Hostname: "dw2", Status: "Down"},
+	}
+
+	node, ok, err := nodes.FindByHostname("dm1")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal("1", node.ID)
+
+	node, ok, err = nodes.FindByHostname("dw1")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal("3", node.ID)
+
+	_, ok, err = nodes.FindByHostname("dw2")
+	assert.ErrorIs(err, ErrAmbiguousNode)
+	assert.False(ok)
+
+	_, ok, err = nodes.FindByHostname("dw3")
+	assert.Nil(err)
+	assert.False(ok)
+}
+
+// TestTaskTerminated tests that tasks in any terminal state are not active.
+func TestTaskTerminated(t *testing.T) {
+	assert := assert.New(t)
+
+	tasks := Tasks{
+		{Name: "web.1", CurrentState: "Running 1 hour ago"},
+		{Name: "job.1", CurrentState: "Complete 5 minutes ago"},
+		{Name: "web.2", CurrentState: "Shutdown 1 second ago"},
+		{Name: "web.3", CurrentState: "Failed 1 minute ago"},
+	}
+	assert.Equal(1, tasks.Active())
+	assert.False(tasks.AllShutdown())
+	assert.True(tasks[1:].AllShutdown())
+}
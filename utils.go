@@ -21,10 +21,18 @@
 package swarm
 
 import (
+	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
+// ShellQuote quotes s with single quotes so it is passed as a single literal
+// argument by a POSIX shell.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 func ParseLabels(q string) (url.Values, error) {
 	q = strings.TrimSpace(q)
 	if q == "" {
@@ -34,6 +42,75 @@ func ParseLabels(q string) (url.Values, error) {
 	return url.ParseQuery(q)
 }
 
+// LabelValue joins the non-empty values of a label into the single value
+// applied to the node in the form `value1,value2`.
+func LabelValue(values []string) string {
+	var nonEmpty []string
+	for _, value := range values {
+		if value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+
+	return strings.Join(nonEmpty, ",")
+}
+
+// FormatLabel formats a label key and its values in the form accepted by
+// `docker node update --label-add`. A key with no (non-empty) values is
+// formatted as just the key, otherwise as `key=value1,value2`.
+func FormatLabel(key string, values []string) string {
+	value := LabelValue(values)
+	if value == "" {
+		return key
+	}
+
+	return fmt.Sprintf("%s=%s", key, value)
+}
+
+// CompareVersions compares two Docker versions such as `20.10.12` or
+// `24.0.7-ce` numerically component by component and returns -1, 0 or 1 if
+// a is older, the same or newer than b. Any suffix after a `-` or `+` is
+// ignored.
+func CompareVersions(a, b string) int {
+	as, bs := versionParts(a), versionParts(b)
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func versionParts(version string) []int {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+
+	var parts []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+
+	return parts
+}
+
 func HasString(a []string, x string) bool {
 	for _, n := range a {
 		if x == n {
@@ -0,0 +1,61 @@
+/*
+	go-swarm is a Go library and ccommand-line tool for managing the creation
+	and maintenance of Docker Swarm cluster.
+
+    Copyright (C) 2021 Sovereign Cloud Australia Pty Ltd
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU Affero General Public License as published
+    by the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU Affero General Public License for more details.
+
+    You should have received a copy of the GNU Affero General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package swarm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFormatLabel tests that `FormatLabel()` only appends values to the label
+// key when there are any.
+func TestFormatLabel(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("key", FormatLabel("key", nil))
+	assert.Equal("key", FormatLabel("key", []string{}))
+	assert.Equal("key", FormatLabel("key", []string{""}))
+	assert.Equal("key=v1", FormatLabel("key", []string{"v1"}))
+	assert.Equal("key=v1,v2", FormatLabel("key", []string{"v1", "v2"}))
+}
+
+// TestParseLabelsFormat tests that labels parsed from a LabelsTag are
+// formatted correctly including keys without values.
+func TestParseLabelsFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	labels, err := ParseLabels("key1=value1&key2")
+	assert.Nil(err)
+	assert.Equal("key1=value1", FormatLabel("key1", labels["key1"]))
+	assert.Equal("key2", FormatLabel("key2", labels["key2"]))
+}
+
+// TestCompareVersions tests comparing Docker versions.
+func TestCompareVersions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(0, CompareVersions("20.10.12", "20.10.12"))
+	assert.Equal(-1, CompareVersions("18.09.1", "19.03.0"))
+	assert.Equal(1, CompareVersions("24.0.7", "20.10.12"))
+	assert.Equal(1, CompareVersions("20.10.12-ce", "20.10"))
+	assert.Equal(0, CompareVersions("19.03", "19.03.0"))
+}